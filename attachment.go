@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Jeffail/gabs/v2"
+	"github.com/theist/slatemess/slatemess"
+)
+
+// sidebar color for each -status
+var statusColors = map[string]string{
+	"ok":      "#2eb886",
+	"warning": "#daa038",
+	"error":   "#a30200",
+	"info":    "#439fe0",
+}
+
+var hexColorRe = regexp.MustCompile(`^#?[0-9a-fA-F]{6}$`)
+
+func statusNames() []string {
+	var names []string
+	for name := range statusColors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Checks an attachment color, the names slack knows or an hex color
+func checkColor(color string) (string, error) {
+	switch {
+	case stringIn(color, []string{"good", "warning", "danger"}):
+		return color, nil
+	case hexColorRe.MatchString(color):
+		return "#" + strings.TrimPrefix(color, "#"), nil
+	}
+	return "", fmt.Errorf("invalid color %v, use good, warning, danger or an hex color like #2eb886", color)
+}
+
+// Moves the text or blocks of a payload into an attachment with a colored
+// sidebar. The text becomes the fallback for notifications. Payloads with
+// neither are kept as they are.
+func attachPayload(payload, color, status string) (string, error) {
+	js, err := gabs.ParseJSON([]byte(payload))
+	if err != nil {
+		return "", withCode(exitValidation, "error building attachment", err)
+	}
+	text, _ := js.Path("text").Data().(string)
+	if text == "" && !js.Exists("blocks") {
+		logDebug.Printf("no text or blocks to attach, the payload is kept")
+		return payload, nil
+	}
+	attachment := gabs.New()
+	attachment.Set(color, "color")
+	fallback := text
+	if fallback == "" {
+		fallback = status
+	}
+	attachment.Set(fallback, "fallback")
+	if js.Exists("blocks") {
+		attachment.Set(js.Path("blocks").Data(), "blocks")
+		js.Delete("blocks")
+	} else {
+		attachment.Set(text, "text")
+		attachment.Set([]string{"text"}, "mrkdwn_in")
+	}
+	js.Delete("text")
+	footer := []string{}
+	if status != "" {
+		footer = append(footer, status)
+	}
+	if host, err := os.Hostname(); err == nil {
+		footer = append(footer, host)
+	}
+	attachment.Set(strings.Join(footer, " · "), "footer")
+	attachment.Set(time.Now().Unix(), "ts")
+	attachments := []interface{}{attachment.Data()}
+	for _, existing := range js.Path("attachments").Children() {
+		attachments = append(attachments, existing.Data())
+	}
+	js.Set(attachments, "attachments")
+	return slatemess.MarshalPayload(js.Data())
+}
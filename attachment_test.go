@@ -0,0 +1,47 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/Jeffail/gabs/v2"
+)
+
+func TestAttachPayload(t *testing.T) {
+	tests := []struct {
+		name        string
+		payload     string
+		attachments int
+		text        string
+		blocks      bool
+	}{
+		{"text", `{"text":"deploy done"}`, 1, "deploy done", false},
+		{"blocks", `{"text":"fallback","blocks":[{"type":"divider"}]}`, 1, "", true},
+		{"text with attachments", `{"text":"hi","attachments":[{"text":"old"}]}`, 2, "hi", false},
+		{"only attachments", `{"attachments":[{"text":"old"}]}`, 1, "old", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := attachPayload(tt.payload, "#2eb886", "ok")
+			if err != nil {
+				t.Fatalf("attachPayload() error = %v", err)
+			}
+			js, err := gabs.ParseJSON([]byte(got))
+			if err != nil {
+				t.Fatalf("attachPayload() = %v, not json: %v", got, err)
+			}
+			attachments := js.Path("attachments").Children()
+			if len(attachments) != tt.attachments {
+				t.Fatalf("attachPayload() has %d attachments, want %d: %v", len(attachments), tt.attachments, got)
+			}
+			if text, _ := attachments[0].Path("text").Data().(string); text != tt.text {
+				t.Errorf("attachPayload() first attachment text = %q, want %q", text, tt.text)
+			}
+			if attachments[0].Exists("blocks") != tt.blocks {
+				t.Errorf("attachPayload() first attachment blocks = %v, want %v", attachments[0].Exists("blocks"), tt.blocks)
+			}
+			if js.Exists("text") || js.Exists("blocks") {
+				t.Errorf("attachPayload() = %v, text or blocks left out of the attachment", got)
+			}
+		})
+	}
+}
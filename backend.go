@@ -0,0 +1,103 @@
+package main
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/Jeffail/gabs/v2"
+	"github.com/theist/slatemess/slatemess"
+)
+
+// the services a hook can belong to, slatemess payloads are slack ones
+const (
+	backendSlack      = "slack"
+	backendDiscord    = "discord"
+	backendMattermost = "mattermost"
+)
+
+var (
+	slackHookRe      = regexp.MustCompile(`^/(services/T[A-Z0-9]+/B[A-Z0-9]+/[A-Za-z0-9]+|workflows/T[A-Z0-9]+/A[A-Z0-9]+/[0-9]+/[A-Za-z0-9]+|triggers/T[A-Z0-9]+/[0-9]+/[A-Za-z0-9]+)$`)
+	discordHookRe    = regexp.MustCompile(`^/api/webhooks/[0-9]+/[A-Za-z0-9_-]+(/slack)?$`)
+	mattermostHookRe = regexp.MustCompile(`^(/.*)?/hooks/[a-z0-9]{26}$`)
+	emojiRe          = regexp.MustCompile(`^:?[a-z0-9_+'-]+:?$`)
+)
+
+// payload fields each service names its own way
+var backendFields = map[string]map[string]string{
+	backendDiscord: {"icon_url": "avatar_url"},
+}
+
+// Guesses the service of a hook by its host and path, empty if unknown
+func hookBackend(u *url.URL) string {
+	host := strings.ToLower(u.Hostname())
+	switch {
+	case host == "hooks.slack.com":
+		return backendSlack
+	case host == "discord.com" || host == "discordapp.com" || strings.HasSuffix(host, ".discord.com"):
+		return backendDiscord
+	case mattermostHookRe.MatchString(u.Path):
+		return backendMattermost
+	}
+	return ""
+}
+
+// Checks the path of a hook has the shape its service uses
+func hookShapeValid(backend string, u *url.URL) bool {
+	switch backend {
+	case backendSlack:
+		return slackHookRe.MatchString(u.Path)
+	case backendDiscord:
+		return discordHookRe.MatchString(u.Path)
+	case backendMattermost:
+		return mattermostHookRe.MatchString(u.Path)
+	}
+	return true
+}
+
+func hookURLBackend(hook string) string {
+	u, err := url.Parse(hook)
+	if err != nil {
+		return ""
+	}
+	return hookBackend(u)
+}
+
+// Renames the payload fields the service of the hook names differently
+func backendPayload(hook, payload string) (string, error) {
+	renames := backendFields[hookURLBackend(hook)]
+	if len(renames) == 0 {
+		return payload, nil
+	}
+	js, err := gabs.ParseJSON([]byte(payload))
+	if err != nil {
+		return "", withCode(exitValidation, "error adapting payload", err)
+	}
+	for from, to := range renames {
+		if js.Exists(from) && !js.Exists(to) {
+			js.Set(js.Path(from).Data(), to)
+			js.Delete(from)
+		}
+	}
+	return slatemess.MarshalPayload(js.Data())
+}
+
+func isURL(s string) bool {
+	return strings.HasPrefix(s, "https://") || strings.HasPrefix(s, "http://")
+}
+
+// Sets the icon, that can be an emoji, with or without colons, or the url
+// of an image. An icon url set on its own wins over an url here.
+func (c *config) setIcon(icon string) {
+	switch {
+	case icon == "":
+	case isURL(icon):
+		if c.iconURL == "" {
+			c.iconURL = icon
+		}
+	case emojiRe.MatchString(icon):
+		c.icon = ":" + strings.Trim(icon, ":") + ":"
+	default:
+		c.icon = icon
+	}
+}
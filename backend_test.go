@@ -0,0 +1,28 @@
+package main
+
+import "testing"
+
+func TestBackendPayload(t *testing.T) {
+	tests := []struct {
+		name    string
+		hook    string
+		payload string
+		want    string
+	}{
+		{"discord icon url", "https://discord.com/api/webhooks/1/a/slack", `{"icon_url":"https://x/i.png","text":"a & b"}`, `{"avatar_url":"https://x/i.png","text":"a & b"}`},
+		{"discord avatar kept", "https://discord.com/api/webhooks/1/a/slack", `{"avatar_url":"https://x/a.png","icon_url":"https://x/i.png"}`, `{"avatar_url":"https://x/a.png","icon_url":"https://x/i.png"}`},
+		{"slack", "https://hooks.slack.com/services/T1/B1/x", `{"icon_url":"https://x/i.png"}`, `{"icon_url":"https://x/i.png"}`},
+		{"mattermost", "https://mm.example.com/hooks/abcdefghijklmnopqrstuvwxyz", `{"icon_url":"https://x/i.png"}`, `{"icon_url":"https://x/i.png"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := backendPayload(tt.hook, tt.payload)
+			if err != nil {
+				t.Fatalf("backendPayload() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("backendPayload() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,369 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/theist/slatemess/slatemess"
+)
+
+// message for digest when there's no -message, -file or -template
+const defaultDigestTemplate = "*{{ .KEY }}*: {{ .COUNT }} events from {{ len .HOSTS }} hosts" +
+	"{{ range .GROUPS }}\n\n{{ .Emoji }} *{{ .Status }}* ({{ len .Events }})" +
+	"{{ range .Events }}\n• `{{ .Host }}` {{ .Text }}{{ end }}{{ end }}"
+
+// order of the status groups in a digest, worst first, others go last
+var digestStatuses = []string{"error", "warning", "info", "ok"}
+
+var statusEmojis = map[string]string{
+	"ok":      ":white_check_mark:",
+	"warning": ":warning:",
+	"error":   ":x:",
+	"info":    ":information_source:",
+}
+
+var collectKeyRe = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// an event kept by collect for the digest of its key
+type event struct {
+	Time   time.Time `json:"time"`
+	Host   string    `json:"host"`
+	Status string    `json:"status"`
+	Text   string    `json:"text"`
+}
+
+// the events of a status in a digest, by host
+type eventGroup struct {
+	Status string
+	Emoji  string
+	Events []event
+	Hosts  []string
+}
+
+func collectDir() string {
+	return filepath.Join(slatemessDir(), "collect")
+}
+
+func collectFile(key string) string {
+	return filepath.Join(collectDir(), key+".jsonl")
+}
+
+func checkCollectKey(key string) error {
+	if !collectKeyRe.MatchString(key) {
+		return withCode(exitConfig, "", fmt.Errorf("invalid -key %q, use letters, digits, _, . and -", key))
+	}
+	return nil
+}
+
+// Appends events to a key file, each in a single write so collects running
+// at the same time don't mix their lines
+func appendEvents(file string, events []event) error {
+	err := os.MkdirAll(filepath.Dir(file), 0700)
+	if err != nil {
+		return fmt.Errorf("error creating %v: %v", filepath.Dir(file), err)
+	}
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("error opening %v: %v", file, err)
+	}
+	defer f.Close()
+	for _, e := range events {
+		line, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		_, err = f.Write(append(line, '\n'))
+		if err != nil {
+			return fmt.Errorf("error writing %v: %v", file, err)
+		}
+	}
+	return nil
+}
+
+func readEvents(file string) ([]event, error) {
+	f, err := os.Open(file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error opening %v: %v", file, err)
+	}
+	defer f.Close()
+	var events []event
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var e event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			logDebug.Printf("WARN: ignoring event in %v: %v", file, err)
+			continue
+		}
+		events = append(events, e)
+	}
+	return events, scanner.Err()
+}
+
+// Takes the events of a key for a digest, moving its file away so events
+// collected meanwhile go to the next one. The file returned is removed
+// once sent, or its events are given back with returnEvents.
+func claimEvents(key string) (string, []event, error) {
+	claimed := fmt.Sprintf("%v.%d.digest", collectFile(key), time.Now().UnixNano())
+	err := os.Rename(collectFile(key), claimed)
+	if os.IsNotExist(err) {
+		return "", nil, nil
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("error taking events of %v: %v", key, err)
+	}
+	events, err := readEvents(claimed)
+	if err != nil {
+		return "", nil, fmt.Errorf("%v, the events are kept in %v", err, claimed)
+	}
+	return claimed, events, nil
+}
+
+// Puts back the events of a digest that couldn't be sent
+func returnEvents(key, claimed string, events []event) {
+	err := appendEvents(collectFile(key), events)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "WARN events of %v kept in %v: %v\n", key, claimed, err)
+		return
+	}
+	os.Remove(claimed)
+}
+
+func statusRank(status string) int {
+	for i, s := range digestStatuses {
+		if s == status {
+			return i
+		}
+	}
+	return len(digestStatuses)
+}
+
+// Groups events by status, worst first, and each group by host and time
+func groupEvents(events []event) []eventGroup {
+	byStatus := make(map[string][]event)
+	var statuses []string
+	for _, e := range events {
+		if byStatus[e.Status] == nil {
+			statuses = append(statuses, e.Status)
+		}
+		byStatus[e.Status] = append(byStatus[e.Status], e)
+	}
+	sort.SliceStable(statuses, func(i, j int) bool {
+		if statusRank(statuses[i]) != statusRank(statuses[j]) {
+			return statusRank(statuses[i]) < statusRank(statuses[j])
+		}
+		return statuses[i] < statuses[j]
+	})
+	var groups []eventGroup
+	for _, status := range statuses {
+		group := eventGroup{Status: status, Emoji: statusEmojis[status], Events: byStatus[status]}
+		sort.SliceStable(group.Events, func(i, j int) bool {
+			if group.Events[i].Host != group.Events[j].Host {
+				return group.Events[i].Host < group.Events[j].Host
+			}
+			return group.Events[i].Time.Before(group.Events[j].Time)
+		})
+		group.Hosts = eventHosts(group.Events)
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+func eventHosts(events []event) []string {
+	seen := make(map[string]bool)
+	var hosts []string
+	for _, e := range events {
+		if !seen[e.Host] {
+			seen[e.Host] = true
+			hosts = append(hosts, e.Host)
+		}
+	}
+	sort.Strings(hosts)
+	return hosts
+}
+
+// Renders the events of a digest with its template. The template gets the
+// env and the events, as they are and grouped by status. Unless -overflow
+// says what to do with a digest too long for a message, only the worst
+// events are listed, with a line telling how many more there are.
+func renderDigest(ctx context.Context, c config, client *slatemess.Client, key string, events []event) (string, error) {
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Time.Before(events[j].Time) })
+	message, err := digestMessage(ctx, c, client, key, events, events)
+	shown := len(events)
+	for err == nil && c.overflow == "send" && shown > 1 && len([]rune(message)) > maxTextLength {
+		fit := shown * maxTextLength * 9 / (10 * len([]rune(message)))
+		switch {
+		case fit >= shown:
+			shown--
+		case fit < 1:
+			shown = 1
+		default:
+			shown = fit
+		}
+		message, err = digestMessage(ctx, c, client, key, events, worstEvents(events, shown))
+	}
+	if err == nil && c.overflow == "send" {
+		// a single event can be too long too
+		message = truncateText(message)
+	}
+	return message, err
+}
+
+// The n worst events, the ones a cut digest lists, oldest first
+func worstEvents(events []event, n int) []event {
+	var worst []event
+	for _, group := range groupEvents(events) {
+		worst = append(worst, group.Events...)
+	}
+	worst = worst[:n]
+	sort.SliceStable(worst, func(i, j int) bool { return worst[i].Time.Before(worst[j].Time) })
+	return worst
+}
+
+func digestMessage(ctx context.Context, c config, client *slatemess.Client, key string, events, shown []event) (string, error) {
+	data := make(map[string]interface{})
+	for name, value := range dictEnviron() {
+		data[name] = value
+	}
+	data["KEY"] = key
+	data["COUNT"] = len(events)
+	data["EVENTS"] = shown
+	data["GROUPS"] = groupEvents(shown)
+	data["HOSTS"] = eventHosts(events)
+	data["FIRST"] = events[0].Time.Format(time.RFC3339)
+	data["LAST"] = events[len(events)-1].Time.Format(time.RFC3339)
+	data["MORE"] = len(events) - len(shown)
+	message, err := renderTemplate(ctx, client, c.message, data)
+	if err != nil || len(shown) == len(events) {
+		return message, err
+	}
+	return message + fmt.Sprintf("\n…and %d more events", len(events)-len(shown)), nil
+}
+
+func setupCollect(fs *flag.FlagSet) func([]string) {
+	key := fs.String("key", "", "Name of the batch to add the event to")
+	status := fs.String("status", "info", "Status of the event, to group it in the digest: "+strings.Join(statusNames(), "|"))
+	host := fs.String("host", "", "Host of the event, this host name by default")
+	message := fs.String("message", "", "Provide the event text by parameter")
+	file := fs.String("file", "", "Provide the event text by file")
+	debug := fs.Bool("debug", false, "Print debug info")
+	return func(args []string) {
+		if !*debug {
+			logDebug.SetOutput(ioutil.Discard)
+		}
+		err := checkCollectKey(*key)
+		if err != nil {
+			exit("text", result{}, err)
+		}
+		if _, ok := statusColors[*status]; !ok {
+			exit("text", result{}, withCode(exitConfig, "", fmt.Errorf("unknown status %v, valid statuses are %v", *status, strings.Join(statusNames(), ", "))))
+		}
+		e := event{Time: time.Now(), Host: *host, Status: *status}
+		if e.Host == "" {
+			e.Host, _ = os.Hostname()
+		}
+		text := *message
+		switch {
+		case *message != "" && *file != "":
+			err = withCode(exitConfig, "", fmt.Errorf("-file and -message are mutually exclusive"))
+		case *file != "":
+			text, err = readFileNameAsStr(*file)
+		case *message == "":
+			var piped bool
+			piped, err = stdinPiped()
+			if piped {
+				text = cleanTerminal(readStdin(), false)
+			}
+		}
+		if err != nil {
+			exit("text", result{}, withCode(exitInput, "", err))
+		}
+		// rendered now, with this host env
+		text, err = messageRender(text, nil, template.FuncMap{"fence": fenceFunc})
+		if err != nil {
+			exit("text", result{}, err)
+		}
+		e.Text = strings.TrimSpace(text)
+		if e.Text == "" {
+			exit("text", result{}, withCode(exitInput, "", fmt.Errorf("missing message")))
+		}
+		err = appendEvents(collectFile(*key), []event{e})
+		if err != nil {
+			exit("text", result{}, withCode(exitInput, "", err))
+		}
+		logDebug.Printf("collected %v event from %v in %v", e.Status, e.Host, collectFile(*key))
+	}
+}
+
+func setupDigest(fs *flag.FlagSet) func([]string) {
+	f := addMessageFlags(fs)
+	key := fs.String("key", "", "Name of the batch to send")
+	return func(args []string) {
+		c, err := f.config(false)
+		if err == nil {
+			err = noArgs(args)
+		}
+		if err != nil {
+			exit(f.outputMode(), result{}, err)
+		}
+		res := result{Target: target(c)}
+		err = checkCollectKey(*key)
+		if err == nil && c.input != "" {
+			err = withCode(exitConfig, "", fmt.Errorf("-input can't be used with digest"))
+		}
+		if err != nil {
+			exit(c.output, res, err)
+		}
+		// with -dry the events are only read, to be sent later
+		claimed := ""
+		var events []event
+		if c.dry {
+			events, err = readEvents(collectFile(*key))
+		} else {
+			claimed, events, err = claimEvents(*key)
+		}
+		if err != nil {
+			exit(c.output, res, withCode(exitInput, "", err))
+		}
+		if len(events) == 0 {
+			res.Status = "empty"
+			if c.output != "json" {
+				fmt.Fprintf(os.Stderr, "no events collected for %v\n", *key)
+			}
+			exit(c.output, res, nil)
+		}
+		if c.message == "" {
+			c.message = defaultDigestTemplate
+		}
+		c.renderer = func(ctx context.Context, c config, client *slatemess.Client) (string, error) {
+			return renderDigest(ctx, c, client, *key, events)
+		}
+		ctx, stop := signalContext()
+		defer stop()
+		res, err = send(ctx, c)
+		stop()
+		if claimed != "" {
+			// a spooled digest is sent by flush, its events are done
+			if err != nil && res.Status != "spooled" {
+				returnEvents(*key, claimed, events)
+			} else {
+				os.Remove(claimed)
+			}
+		}
+		exit(c.output, res, err)
+	}
+}
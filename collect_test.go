@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// n events from as many hosts, every tenth one an error
+func sampleEvents(n int) []event {
+	start := time.Date(2026, 10, 19, 3, 0, 0, 0, time.UTC)
+	var events []event
+	for i := 0; i < n; i++ {
+		status := "ok"
+		if i%10 == 0 {
+			status = "error"
+		}
+		events = append(events, event{Time: start.Add(time.Duration(i) * time.Minute), Host: fmt.Sprintf("host%03d", i), Status: status, Text: strings.Repeat("backup log line ", 5)})
+	}
+	return events
+}
+
+func TestRenderDigest(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	tests := []struct {
+		name     string
+		events   int
+		overflow string
+		more     bool
+	}{
+		{"fits", 10, "send", false},
+		{"cut", 200, "send", true},
+		{"not cut with upload", 200, "upload", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := config{message: defaultDigestTemplate, overflow: tt.overflow}
+			got, err := renderDigest(context.Background(), c, nil, "nightly", sampleEvents(tt.events))
+			if err != nil {
+				t.Fatalf("renderDigest() error = %v", err)
+			}
+			if !strings.HasPrefix(got, fmt.Sprintf("*nightly*: %d events from %d hosts", tt.events, tt.events)) {
+				t.Errorf("renderDigest() = %.60q, want the count of every event", got)
+			}
+			if more := strings.Contains(got, "more events"); more != tt.more {
+				t.Errorf("renderDigest() has more line = %v, want %v", more, tt.more)
+			}
+			if tt.overflow == "send" && len([]rune(got)) > maxTextLength {
+				t.Errorf("renderDigest() is %d long, over %d", len([]rune(got)), maxTextLength)
+			}
+			// the ok events are cut first
+			for i := 0; i < tt.events; i += 10 {
+				if !strings.Contains(got, fmt.Sprintf("`host%03d`", i)) {
+					t.Errorf("renderDigest() misses the error of host%03d", i)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// A subcommand. setup registers the command flags and returns the action,
+// that is run with the remaining arguments once the flags are parsed.
+type command struct {
+	name    string
+	args    string
+	summary string
+	hidden  bool
+	setup   func(fs *flag.FlagSet) func(args []string)
+}
+
+// the commands, send is also run for flag only invocations
+var commands []*command
+
+func init() {
+	commands = []*command{
+		{name: "send", summary: "Send a message, the default command", setup: setupSend},
+		{name: "run", args: "[--] command [args]", summary: "Run a command and send its output", setup: setupRun},
+		{name: "serve", summary: "Send the messages posted to an HTTP endpoint", setup: setupServe},
+		{name: "render", summary: "Print the payload, or a preview of the message, without sending it", setup: setupRender},
+		{name: "validate", summary: "Check the payload against slack limits without sending it", setup: setupValidate},
+		{name: "react", summary: "Add or remove a reaction to a message", setup: setupReact},
+		{name: "scheduled", args: "list | delete id...", summary: "List or delete the messages scheduled with -at or -in", setup: setupScheduled},
+		{name: "collect", summary: "Add an event to a batch, to be sent by digest", setup: setupCollect},
+		{name: "digest", summary: "Send the events of a batch in a single message and clear it", setup: setupDigest},
+		{name: "flush", summary: "Send the messages kept in the spool", setup: setupFlush},
+		{name: "config", summary: "Print the resolved configuration", setup: setupConfig},
+		{name: "doctor", summary: "Report where the configuration comes from and check it", setup: setupDoctor},
+		{name: "completion", args: "bash|zsh|fish", summary: "Print a shell completion script", setup: setupCompletion},
+		{name: "__complete", hidden: true, setup: setupComplete},
+	}
+}
+
+func findCommand(name string) *command {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+func commandNames() []string {
+	var names []string
+	for _, cmd := range commands {
+		if !cmd.hidden {
+			names = append(names, cmd.name)
+		}
+	}
+	return names
+}
+
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: slatemess [command] [flags]\n\nCommands:\n")
+	for _, cmd := range commands {
+		if !cmd.hidden {
+			fmt.Fprintf(out, "  %-12v %v\n", cmd.name, cmd.summary)
+		}
+	}
+	fmt.Fprintf(out, "\nWithout a command, the flags are the send ones. Use slatemess <command> -h for each command flags.\n")
+}
+
+func newFlagSet(cmd *command) *flag.FlagSet {
+	fs := flag.NewFlagSet("slatemess "+cmd.name, flag.ExitOnError)
+	fs.Usage = func() {
+		out := fs.Output()
+		fmt.Fprintf(out, "Usage: slatemess %v [flags] %v\n\n%v\n\n", cmd.name, cmd.args, cmd.summary)
+		if cmd.name == "send" {
+			usage()
+			fmt.Fprintln(out)
+		}
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// Runs the command named by the first argument, or send if the first
+// argument is a flag
+func runCommand(args []string) {
+	cmd := findCommand("send")
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		if args[0] == "help" {
+			usage()
+			return
+		}
+		cmd = findCommand(args[0])
+		if cmd == nil {
+			fmt.Fprintf(os.Stderr, "ERROR unknown command %v\n\n", args[0])
+			usage()
+			os.Exit(exitConfig)
+		}
+		args = args[1:]
+	}
+	fs := newFlagSet(cmd)
+	action := cmd.setup(fs)
+	fs.Parse(args)
+	action(fs.Args())
+}
+
+// Flag names of a command, for completions
+func commandFlags(cmd *command) []string {
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	cmd.setup(fs)
+	var names []string
+	fs.VisitAll(func(f *flag.Flag) {
+		names = append(names, "-"+f.Name)
+	})
+	sort.Strings(names)
+	return names
+}
+
+func setupSend(fs *flag.FlagSet) func([]string) {
+	f := addMessageFlags(fs)
+	return func(args []string) {
+		c, err := f.config(true)
+		if err == nil {
+			err = noArgs(args)
+		}
+		if err != nil {
+			exit(f.outputMode(), result{}, err)
+		}
+		ctx, stop := signalContext()
+		defer stop()
+		res, err := send(ctx, c)
+		stop()
+		exit(c.output, res, err)
+	}
+}
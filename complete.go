@@ -0,0 +1,135 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// The completion scripts ask slatemess itself for the candidates, so they
+// include the current profiles and templates. No candidates means files.
+var completionScripts = map[string]string{
+	"bash": `_slatemess() {
+    local cur="${COMP_WORDS[COMP_CWORD]}"
+    local IFS=$'\n'
+    COMPREPLY=($(slatemess __complete -- "${COMP_WORDS[@]:1:COMP_CWORD}"))
+    if [ ${#COMPREPLY[@]} -eq 0 ]; then
+        COMPREPLY=($(compgen -f -- "$cur"))
+    fi
+}
+complete -F _slatemess slatemess
+`,
+	"zsh": `#compdef slatemess
+_slatemess() {
+    local -a candidates
+    candidates=("${(@f)$(slatemess __complete -- "${(@)words[2,CURRENT]}")}")
+    if [[ -n "${candidates[1]}" ]]; then
+        compadd -- $candidates
+    else
+        _files
+    fi
+}
+compdef _slatemess slatemess
+`,
+	"fish": `function __slatemess_complete
+    set -l words (commandline -opc) (commandline -ct)
+    slatemess __complete -- $words[2..-1]
+end
+complete -c slatemess -a '(__slatemess_complete)'
+`,
+}
+
+// Names of the files in a directory, like the profiles or the templates
+func dirNames(dir string) []string {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	return names
+}
+
+func withPrefix(candidates []string, prefix string) []string {
+	var matches []string
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, prefix) {
+			matches = append(matches, candidate)
+		}
+	}
+	return matches
+}
+
+// Completion candidates for the words typed after slatemess, the last one
+// is the word being completed
+func completions(words []string) []string {
+	if len(words) == 0 {
+		return nil
+	}
+	current := words[len(words)-1]
+	previous := ""
+	if len(words) > 1 {
+		previous = strings.TrimLeft(words[len(words)-2], "-")
+	}
+	switch previous {
+	case "profile":
+		return withPrefix(dirNames(profileDir()), current)
+	case "template":
+		return withPrefix(dirNames(templateDir()), current)
+	case "dry-format":
+		return withPrefix(dryFormatNames(), current)
+	case "overflow":
+		return withPrefix(overflowModes(), current)
+	case "input":
+		return withPrefix(inputModeNames(), current)
+	case "status":
+		return withPrefix(statusNames(), current)
+	case "color":
+		return withPrefix([]string{"good", "warning", "danger"}, current)
+	case "output":
+		return withPrefix(outputModes(), current)
+	}
+	if len(words) == 1 && !strings.HasPrefix(current, "-") {
+		return withPrefix(commandNames(), current)
+	}
+	cmd := findCommand("send")
+	if !strings.HasPrefix(words[0], "-") {
+		cmd = findCommand(words[0])
+	}
+	if cmd == nil {
+		return nil
+	}
+	if cmd.name == "completion" && len(words) == 2 {
+		return withPrefix([]string{"bash", "zsh", "fish"}, current)
+	}
+	if cmd.name == "scheduled" && len(words) == 2 && !strings.HasPrefix(current, "-") {
+		return withPrefix([]string{"list", "delete"}, current)
+	}
+	if strings.HasPrefix(current, "-") {
+		return withPrefix(commandFlags(cmd), current)
+	}
+	return nil
+}
+
+func setupComplete(fs *flag.FlagSet) func([]string) {
+	return func(args []string) {
+		for _, candidate := range completions(args) {
+			fmt.Println(candidate)
+		}
+	}
+}
+
+func setupCompletion(fs *flag.FlagSet) func([]string) {
+	return func(args []string) {
+		if len(args) != 1 || completionScripts[args[0]] == "" {
+			exit("text", result{}, withCode(exitConfig, "", fmt.Errorf("completion needs a shell: bash, zsh or fish")))
+		}
+		fmt.Fprint(os.Stdout, completionScripts[args[0]])
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Hides the secret part of a hook, its path
+func maskHook(hook string) string {
+	u, err := url.Parse(hook)
+	if err != nil || u.Host == "" {
+		return maskSecret(hook)
+	}
+	return u.Scheme + "://" + u.Host + "/****"
+}
+
+// Shows only the start of a secret, enough to tell which one is it
+func maskSecret(secret string) string {
+	if len(secret) <= 8 {
+		return strings.Repeat("*", len(secret))
+	}
+	return secret[:5] + "****"
+}
+
+// Prints the settings and the layer each came from
+func printConfig(c config) {
+	settings := [][]string{
+		{"profile", c.profile, "SLATEMESS_PROFILE"},
+		{"hook", maskHook(c.hook), "SLACK_HOOK"},
+		{"token", maskSecret(c.token), "SLACK_TOKEN"},
+		{"channel", c.channel, "SLACK_CHANNEL"},
+		{"user", c.userName, "SLACK_USER"},
+		{"icon", c.icon, "SLACK_ICON"},
+		{"icon url", c.iconURL, "SLACK_ICON_URL"},
+		{"timeout", c.timeout.String(), ""},
+		{"connect timeout", c.connectTimeout.String(), ""},
+		{"proxy", maskHook(c.network.Proxy), "SLACK_PROXY"},
+		{"proxy user", c.network.ProxyUser, "SLACK_PROXY_USER"},
+		{"CA bundle", c.network.CABundle, "SLACK_CA_BUNDLE"},
+		{"client cert", c.network.ClientCert, "SLACK_CLIENT_CERT"},
+		{"client key", c.network.ClientKey, "SLACK_CLIENT_KEY"},
+		{"allowed hosts", strings.Join(c.network.AllowedHosts, ","), "SLACK_ALLOWED_HOSTS"},
+	}
+	for _, setting := range settings {
+		origin := varOrigins[setting[2]]
+		if setting[1] == "" || origin == "" {
+			fmt.Printf("%-16v %v\n", setting[0], setting[1])
+			continue
+		}
+		fmt.Printf("%-16v %-32v (%v)\n", setting[0], setting[1], origin)
+	}
+}
+
+func setupConfig(fs *flag.FlagSet) func([]string) {
+	f := addConfigFlags(fs)
+	return func(args []string) {
+		c, err := f.config()
+		if err != nil {
+			exit(f.outputMode(), result{}, err)
+		}
+		printConfig(c)
+	}
+}
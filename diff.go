@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/theist/slatemess/slatemess"
+)
+
+// files listed in the summary of a diff
+const maxDiffFiles = 20
+
+var hunkRe = regexp.MustCompile(`^@@ -\d+(?:,(\d+))? \+\d+(?:,(\d+))? @@`)
+
+type diffFile struct {
+	name    string
+	added   int
+	removed int
+}
+
+// The name in a ---/+++ line, without the a/ b/ prefixes and timestamps
+func diffName(line string) string {
+	name := strings.SplitN(line[4:], "\t", 2)[0]
+	if strings.HasPrefix(name, "a/") || strings.HasPrefix(name, "b/") {
+		name = name[2:]
+	}
+	return name
+}
+
+func hunkCount(count string) int {
+	if count == "" {
+		return 1
+	}
+	n, _ := strconv.Atoi(count)
+	return n
+}
+
+// Counts the lines added and removed for each file of an unified diff,
+// following the hunk sizes so content lines starting with --- or +++
+// aren't taken as headers
+func parseDiff(text string) []diffFile {
+	var files []diffFile
+	var current *diffFile
+	oldName := ""
+	oldLeft, newLeft := 0, 0
+	for _, line := range strings.Split(text, "\n") {
+		if oldLeft > 0 || newLeft > 0 {
+			switch {
+			case strings.HasPrefix(line, "+"):
+				current.added++
+				newLeft--
+			case strings.HasPrefix(line, "-"):
+				current.removed++
+				oldLeft--
+			case strings.HasPrefix(line, "\\"):
+			default:
+				oldLeft--
+				newLeft--
+			}
+			continue
+		}
+		switch {
+		case strings.HasPrefix(line, "--- "):
+			oldName = diffName(line)
+		case strings.HasPrefix(line, "+++ "):
+			name := diffName(line)
+			if name == "/dev/null" || name == "dev/null" {
+				name = oldName
+			}
+			files = append(files, diffFile{name: name})
+			current = &files[len(files)-1]
+		case current != nil && hunkRe.MatchString(line):
+			m := hunkRe.FindStringSubmatch(line)
+			oldLeft, newLeft = hunkCount(m[1]), hunkCount(m[2])
+		}
+	}
+	return files
+}
+
+func diffSummary(files []diffFile) string {
+	added, removed := 0, 0
+	for _, file := range files {
+		added += file.added
+		removed += file.removed
+	}
+	changed := "files changed"
+	if len(files) == 1 {
+		changed = "file changed"
+	}
+	lines := []string{fmt.Sprintf("*%d %v*, +%d -%d", len(files), changed, added, removed)}
+	for i, file := range files {
+		if i == maxDiffFiles {
+			lines = append(lines, fmt.Sprintf("_and %d more files_", len(files)-maxDiffFiles))
+			break
+		}
+		lines = append(lines, fmt.Sprintf("• `%v` +%d -%d", file.name, file.added, file.removed))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Cuts the diff to the lines fitting in size, returns the lines left out
+func trimDiff(diff string, size int) (string, int) {
+	lines := strings.Split(strings.TrimRight(diff, "\n"), "\n")
+	length := 0
+	for i, line := range lines {
+		length += len([]rune(line)) + 1
+		if length > size {
+			return strings.Join(lines[:i], "\n"), len(lines) - i
+		}
+	}
+	return strings.Join(lines, "\n"), 0
+}
+
+// Whether a diff doesn't fit in a message with its summary
+func diffTooLong(c config, summary string) bool {
+	_, left := trimDiff(c.message, diffBudget(summary))
+	return left > 0
+}
+
+// The summary of the diff, empty if there's no diff, and whether the diff
+// is uploaded as a file as it doesn't fit with -overflow upload
+func uploadedDiff(c config) (string, bool) {
+	files := parseDiff(c.message)
+	if len(files) == 0 {
+		return "", false
+	}
+	summary := diffSummary(files)
+	return summary, c.overflow == "upload" && diffTooLong(c, summary)
+}
+
+// room for the diff in a message, leaving some for the trimmed note
+func diffBudget(summary string) int {
+	return maxTextLength - len([]rune(summary)) - 100
+}
+
+// Summarizes an unified diff and shows it in a code block, trimmed to fit
+// in a message. With -overflow upload only the summary is sent, the diff
+// is uploaded as a file.
+func diffInput(c config) (string, error) {
+	summary, upload := uploadedDiff(c)
+	if summary == "" {
+		return "", withCode(exitInput, "", fmt.Errorf("no unified diff in the input"))
+	}
+	if upload {
+		return summary, nil
+	}
+	diff, left := trimDiff(c.message, diffBudget(summary))
+	message := summary + "\n" + fenceIt(diff, "")
+	if left > 0 {
+		message += fmt.Sprintf("\n_diff trimmed, %d more lines_", left)
+	}
+	return message, nil
+}
+
+// the whole diff as a file, for -overflow upload
+func diffFileUpload(c config, summary string) slatemess.File {
+	return slatemess.File{
+		Name:     "changes.diff",
+		Title:    c.uploadTitle,
+		Type:     "diff",
+		Comment:  slatemess.NewMessage(summary).Text(),
+		Content:  []byte(c.message),
+		Channel:  c.channel,
+		ThreadTS: c.threadTS,
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// a diff of one file changing n lines
+func sampleDiff(n int) string {
+	lines := []string{"--- a/f.txt", "+++ b/f.txt", fmt.Sprintf("@@ -1,%d +1,%d @@", n, n)}
+	for i := 0; i < n; i++ {
+		lines = append(lines, fmt.Sprintf("-old line %03d aaaaaaaaaaaaaaaaaaaaaa", i))
+	}
+	for i := 0; i < n; i++ {
+		lines = append(lines, fmt.Sprintf("+new line %03d bbbbbbbbbbbbbbbbbbbbbb", i))
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func TestParseDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		diff string
+		want []diffFile
+	}{
+		{"empty", "", nil},
+		{"not a diff", "hello\nworld\n", nil},
+		{
+			"one file",
+			"--- a/main.go\n+++ b/main.go\n@@ -1,3 +1,3 @@\n package main\n-var a = 1\n+var a = 2\n \n",
+			[]diffFile{{"main.go", 1, 1}},
+		},
+		{
+			"header like content",
+			"--- a/notes.md\n+++ b/notes.md\n@@ -1,2 +1,2 @@\n---- old rule\n+++++ new rule\n title\n",
+			[]diffFile{{"notes.md", 1, 1}},
+		},
+		{
+			"new and deleted files",
+			"--- /dev/null\n+++ b/new.txt\n@@ -0,0 +1,2 @@\n+one\n+two\n" +
+				"--- a/old.txt\n+++ /dev/null\n@@ -1 +0,0 @@\n-gone\n",
+			[]diffFile{{"new.txt", 2, 0}, {"old.txt", 0, 1}},
+		},
+		{
+			"git diff with timestamps and no newline marker",
+			"diff --git a/x b/x\nindex 1..2 100644\n--- a/x\t2024-01-01\n+++ b/x\t2024-01-02\n@@ -1 +1 @@\n-a\n\\ No newline at end of file\n+b\n",
+			[]diffFile{{"x", 1, 1}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseDiff(tt.diff)
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("parseDiff() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffInputSize(t *testing.T) {
+	tests := []struct {
+		name     string
+		lines    int
+		overflow string
+		upload   bool
+		trimmed  bool
+	}{
+		{"small", 5, "send", false, false},
+		{"small with upload", 5, "upload", false, false},
+		// over half the limit, it still fits
+		{"fits with upload", 40, "upload", false, false},
+		{"too long", 100, "send", false, true},
+		{"too long with upload", 100, "upload", true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := config{message: sampleDiff(tt.lines), overflow: tt.overflow, input: "diff"}
+			summary, upload := uploadedDiff(c)
+			if upload != tt.upload {
+				t.Errorf("uploadedDiff() upload = %v, want %v", upload, tt.upload)
+			}
+			message, err := diffInput(c)
+			if err != nil {
+				t.Fatalf("diffInput() error = %v", err)
+			}
+			if upload && message != summary {
+				t.Errorf("diffInput() = %q, want only the summary %q", message, summary)
+			}
+			if trimmed := strings.Contains(message, "_diff trimmed"); trimmed != tt.trimmed {
+				t.Errorf("diffInput() trimmed = %v, want %v", trimmed, tt.trimmed)
+			}
+			if n := len([]rune(message)); n > maxTextLength {
+				t.Errorf("diffInput() is %d long, over %d", n, maxTextLength)
+			}
+		})
+	}
+}
+
+func TestDiffInputNoDiff(t *testing.T) {
+	_, err := diffInput(config{message: "not a diff"})
+	if exitCode(err) != exitInput {
+		t.Errorf("diffInput() error = %v, want an input error", err)
+	}
+}
+
+func TestDiffInputFences(t *testing.T) {
+	diff := "--- a/README.md\n+++ b/README.md\n@@ -1,3 +1,3 @@\n ```shell\n-make\n+make test\n ```\n"
+	message, err := diffInput(config{message: diff})
+	if err != nil {
+		t.Fatalf("diffInput() error = %v", err)
+	}
+	if n := strings.Count(message, "```"); n != 2 {
+		t.Errorf("diffInput() = %q, has %d fences, want only the 2 of its code block", message, n)
+	}
+}
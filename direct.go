@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/theist/slatemess/slatemess"
+)
+
+// Whether the message is posted with the Web API instead of the hook
+func (c config) viaAPI() bool {
+	return c.api || c.dm != "" || c.ephemeralTo != "" || !c.postAt.IsZero()
+}
+
+// Resolves an email, id or mapped name to a user id, as mentions are
+func resolveUser(ctx context.Context, client *slatemess.Client, user string) (string, error) {
+	mentions := newMentionResolver(client)
+	id, err := mentions.resolve(ctx, mentionUsers, user, mentions.lookupUser)
+	mentions.saveCache()
+	if exitCode(err) == exitFailure {
+		// not a slack error, the user can't be resolved as given
+		return "", withCode(exitConfig, "", err)
+	}
+	return id, err
+}
+
+// Opens the direct message channel for -dm, with -dry only the user is
+// resolved as opening it can't be undone
+func dmChannel(ctx context.Context, client *slatemess.Client, c config) (string, error) {
+	id, err := resolveUser(ctx, client, c.dm)
+	if err != nil {
+		return "", err
+	}
+	if c.dry {
+		return "dm:" + id, nil
+	}
+	return client.OpenDM(ctx, id)
+}
+
+// Posts the payload with the Web API, or prints it with -dry
+func postMessage(ctx context.Context, client *slatemess.Client, c config, payload string, res *result) error {
+	user := ""
+	if c.ephemeralTo != "" {
+		var err error
+		user, err = resolveUser(ctx, client, c.ephemeralTo)
+		if err != nil {
+			return err
+		}
+	}
+	if c.dry {
+		if user != "" {
+			fmt.Printf("post ephemeral to %v in channel %v\n", user, c.channel)
+		} else {
+			fmt.Printf("post to channel %v\n", c.channel)
+		}
+		fmt.Println(prettyPayload(payload))
+		return nil
+	}
+	res.Attempts++
+	var err error
+	if user != "" {
+		res.TS, err = client.PostEphemeral(ctx, payload, user)
+	} else {
+		res.Channel, res.TS, err = client.Post(ctx, payload)
+	}
+	return err
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMessageCompleteDM(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		c       config
+		want    string
+	}{
+		{"text to dm", "hi", config{channel: "D123", dm: "U1"}, "D123"},
+		{"payload channel replaced by the dm", `{"channel": "#public", "text": "hi"}`, config{channel: "D123", dm: "U1"}, "D123"},
+		{"payload channel kept without dm", `{"channel": "#public", "text": "hi"}`, config{channel: "#other"}, "#public"},
+		{"payload channel replaced with override", `{"channel": "#public", "text": "hi"}`, config{channel: "#other", forceOverride: true}, "#other"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload, err := messageComplete(tt.message, tt.c)
+			if err != nil {
+				t.Fatalf("messageComplete() error = %v", err)
+			}
+			var got map[string]interface{}
+			if err := json.Unmarshal([]byte(payload), &got); err != nil {
+				t.Fatalf("messageComplete() = %v, not json: %v", payload, err)
+			}
+			if got["channel"] != tt.want {
+				t.Errorf("messageComplete() channel = %v, want %v", got["channel"], tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// check results, fail makes doctor fail
+const (
+	checkOK   = "ok"
+	checkWarn = "warn"
+	checkFail = "fail"
+)
+
+type check struct {
+	level   string
+	subject string
+	detail  string
+}
+
+func (c check) String() string {
+	return fmt.Sprintf("[%-4v] %-14v %v", c.level, c.subject, c.detail)
+}
+
+// The value of a traced variable as doctor shows it. Secrets are masked,
+// and variables other than settings, that may be anything, aren't shown.
+func showVar(name, value string) string {
+	switch {
+	case name == "SLACK_HOOK" || name == "SLACK_PROXY":
+		return maskHook(value)
+	case stringIn(name, secretVars) || name == "SLATEMESS_SERVE_TOKEN":
+		return maskSecret(value)
+	case !settingVar(name):
+		return "(not shown)"
+	}
+	return value
+}
+
+func printLayers() {
+	fmt.Println("Config layers, in precedence order:")
+	for _, layer := range configLayers {
+		status := "not found"
+		switch {
+		case layer.err != nil:
+			status = fmt.Sprintf("error: %v", layer.err)
+		case layer.found:
+			status = fmt.Sprintf("loaded, %d variables used", len(layer.used))
+		}
+		if layer.path != "" {
+			status += " (" + layer.path + ")"
+		}
+		fmt.Printf("  %-20v %v\n", layer.name, status)
+	}
+	fmt.Println("\nVariables:")
+	var names []string
+	for name := range varOrigins {
+		if tracedVar(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("  %-22v %-20v %v\n", name, varOrigins[name], showVar(name, configVar(name)))
+	}
+	fmt.Println()
+}
+
+func checkHook(c config) []check {
+	if c.hook == "" {
+		return []check{{checkWarn, "hook", "no hook, set SLACK_HOOK to send messages"}}
+	}
+	u, err := url.Parse(c.hook)
+	if err != nil || u.Host == "" {
+		return []check{{checkFail, "hook", "isn't a valid url"}}
+	}
+	checks := []check{}
+	if u.Scheme != "https" {
+		checks = append(checks, check{checkFail, "hook", fmt.Sprintf("scheme is %v, only https is allowed", u.Scheme)})
+	}
+	backend := hookBackend(u)
+	switch {
+	case backend == "":
+		checks = append(checks, check{checkWarn, "hook", fmt.Sprintf("unknown service at %v, it needs to accept slack payloads", u.Host)})
+	case !hookShapeValid(backend, u):
+		checks = append(checks, check{checkFail, "hook", fmt.Sprintf("doesn't look like a %v hook url, check it wasn't truncated", backend)})
+	case backend == backendDiscord && !strings.HasSuffix(u.Path, "/slack"):
+		checks = append(checks, check{checkWarn, "hook", "discord hooks need /slack at the end to accept slack payloads"})
+	default:
+		checks = append(checks, check{checkOK, "hook", fmt.Sprintf("looks like a %v hook", backend)})
+	}
+	if len(c.network.AllowedHosts) > 0 && !stringIn(strings.ToLower(u.Hostname()), lowerAll(c.network.AllowedHosts)) {
+		checks = append(checks, check{checkFail, "allowed hosts", fmt.Sprintf("hook host %v isn't allowed", u.Hostname())})
+	}
+	return checks
+}
+
+func lowerAll(list []string) []string {
+	var lower []string
+	for _, item := range list {
+		lower = append(lower, strings.ToLower(item))
+	}
+	return lower
+}
+
+func checkToken(c config) []check {
+	switch {
+	case c.token == "":
+		return []check{{checkOK, "token", "no token, uploads and mention lookups by email are disabled"}}
+	case strings.HasPrefix(c.token, "xoxb-") || strings.HasPrefix(c.token, "xoxp-"):
+		return []check{{checkOK, "token", "looks like a bot or user token"}}
+	}
+	return []check{{checkWarn, "token", "doesn't look like a bot (xoxb-) or user (xoxp-) token"}}
+}
+
+func checkNetwork(c config) []check {
+	var checks []check
+	n := c.network
+	if n.Proxy != "" {
+		u, err := url.Parse(n.Proxy)
+		switch {
+		case err != nil || u.Host == "":
+			checks = append(checks, check{checkFail, "proxy", "isn't a valid url"})
+		case !stringIn(u.Scheme, []string{"http", "https", "socks5"}):
+			checks = append(checks, check{checkFail, "proxy", fmt.Sprintf("unsupported scheme %v", u.Scheme)})
+		default:
+			timeout := c.connectTimeout
+			if timeout == 0 {
+				timeout = 10 * time.Second
+			}
+			port := u.Port()
+			if port == "" {
+				port = map[string]string{"http": "80", "https": "443", "socks5": "1080"}[u.Scheme]
+			}
+			conn, err := net.DialTimeout("tcp", net.JoinHostPort(u.Hostname(), port), timeout)
+			if err != nil {
+				checks = append(checks, check{checkFail, "proxy", fmt.Sprintf("can't connect: %v", err)})
+			} else {
+				conn.Close()
+				checks = append(checks, check{checkOK, "proxy", fmt.Sprintf("%v is reachable", u.Host)})
+			}
+		}
+	}
+	if n.ProxyUser != "" && n.Proxy == "" && os.Getenv("HTTPS_PROXY") == "" && os.Getenv("https_proxy") == "" {
+		checks = append(checks, check{checkWarn, "proxy", "proxy user is set but there is no proxy"})
+	}
+	if n.CABundle != "" {
+		pem, err := ioutil.ReadFile(n.CABundle)
+		switch {
+		case err != nil:
+			checks = append(checks, check{checkFail, "CA bundle", err.Error()})
+		case !x509.NewCertPool().AppendCertsFromPEM(pem):
+			checks = append(checks, check{checkFail, "CA bundle", fmt.Sprintf("no certificates found in %v", n.CABundle)})
+		default:
+			checks = append(checks, check{checkOK, "CA bundle", fmt.Sprintf("certificates loaded from %v", n.CABundle)})
+		}
+	}
+	if n.ClientCert != "" || n.ClientKey != "" {
+		cert, err := tls.LoadX509KeyPair(n.ClientCert, n.ClientKey)
+		if err != nil {
+			checks = append(checks, check{checkFail, "client cert", err.Error()})
+		} else {
+			leaf, err := x509.ParseCertificate(cert.Certificate[0])
+			switch {
+			case err != nil:
+				checks = append(checks, check{checkFail, "client cert", err.Error()})
+			case time.Now().After(leaf.NotAfter):
+				checks = append(checks, check{checkFail, "client cert", fmt.Sprintf("expired on %v", leaf.NotAfter.Format("2006-01-02"))})
+			default:
+				checks = append(checks, check{checkOK, "client cert", fmt.Sprintf("valid until %v", leaf.NotAfter.Format("2006-01-02"))})
+			}
+		}
+	}
+	return checks
+}
+
+// Sends a test message with the current settings
+func checkPost(ctx context.Context, c config) check {
+	host, _ := os.Hostname()
+	c.message = fmt.Sprintf("slatemess doctor test message from %v", host)
+	c.rawMrkdwn = false
+	res, err := send(ctx, c)
+	if err != nil {
+		return check{checkFail, "test post", err.Error()}
+	}
+	return check{checkOK, "test post", fmt.Sprintf("sent to %v", res.Target)}
+}
+
+func setupDoctor(fs *flag.FlagSet) func([]string) {
+	f := addConfigFlags(fs)
+	post := fs.Bool("post", false, "Send a test message, to -channel or the hook default")
+	return func(args []string) {
+		c, err := f.config()
+		if err != nil {
+			exit(f.outputMode(), result{}, err)
+		}
+		res := result{Target: target(c), Status: "healthy"}
+		var checks []check
+		checks = append(checks, checkHook(c)...)
+		checks = append(checks, checkToken(c)...)
+		checks = append(checks, checkNetwork(c)...)
+		if *post {
+			ctx, stop := signalContext()
+			defer stop()
+			checks = append(checks, checkPost(ctx, c))
+			stop()
+		}
+		var failures []string
+		for _, ch := range checks {
+			if ch.level == checkFail {
+				failures = append(failures, ch.subject+": "+ch.detail)
+			}
+		}
+		if c.output != "json" {
+			printLayers()
+			fmt.Println("Checks:")
+			for _, ch := range checks {
+				fmt.Println("  " + ch.String())
+			}
+		}
+		if len(failures) > 0 {
+			res.Status = "unhealthy"
+			exit(c.output, res, withCode(exitConfig, "doctor found problems", errors.New(strings.Join(failures, "; "))))
+		}
+		exit(c.output, res, nil)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/Jeffail/gabs/v2"
+	"github.com/tidwall/pretty"
+)
+
+const blockKitBuilderURL = "https://app.slack.com/block-kit-builder/#"
+
+// dryFormats maps every -dry-format value to its printer
+var dryFormats = map[string]func(hook, payload string) (string, error){
+	"curl":     toCurl,
+	"httpie":   toHttpie,
+	"json":     toJSON,
+	"raw":      toRaw,
+	"http":     toHTTP,
+	"blockkit": toBlockKit,
+}
+
+func dryFormatNames() []string {
+	var names []string
+	for name := range dryFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// quotes a string to be used as a single shell word
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func prettyPayload(payload string) string {
+	return strings.TrimSpace(string(pretty.Pretty([]byte(payload))))
+}
+
+func toCurl(hook, payload string) (string, error) {
+	return "curl -X POST -H 'Content-type: application/json' " + shellQuote(hook) + " --data " + shellQuote(prettyPayload(payload)), nil
+}
+
+func toHttpie(hook, payload string) (string, error) {
+	return "printf '%s' " + shellQuote(prettyPayload(payload)) + " | http POST " + shellQuote(hook) + " 'Content-type:application/json'", nil
+}
+
+func toJSON(hook, payload string) (string, error) {
+	return prettyPayload(payload), nil
+}
+
+func toRaw(hook, payload string) (string, error) {
+	return payload, nil
+}
+
+// Builds the raw HTTP/1.1 request that would be sent to the hook
+func toHTTP(hook, payload string) (string, error) {
+	u, err := url.Parse(hook)
+	if err != nil {
+		return "", fmt.Errorf("error in url %v: %v", hook, err)
+	}
+	var req strings.Builder
+	fmt.Fprintf(&req, "POST %v HTTP/1.1\r\n", u.RequestURI())
+	fmt.Fprintf(&req, "Host: %v\r\n", u.Host)
+	fmt.Fprintf(&req, "Content-Type: application/json\r\n")
+	fmt.Fprintf(&req, "Content-Length: %d\r\n", len(payload))
+	fmt.Fprintf(&req, "\r\n%v", payload)
+	return req.String(), nil
+}
+
+// Builds a Block Kit Builder preview url for the payload blocks. Plain text
+// payloads are shown as a single section block.
+func toBlockKit(hook, payload string) (string, error) {
+	js, err := gabs.ParseJSON([]byte(payload))
+	if err != nil {
+		return "", fmt.Errorf("error parsing payload: %v", err)
+	}
+	preview := gabs.New()
+	if js.Exists("blocks") {
+		preview.Set(js.Path("blocks").Data(), "blocks")
+	} else {
+		section := gabs.New()
+		section.Set("section", "type")
+		section.Set("mrkdwn", "text", "type")
+		section.Set(js.Path("text").Data(), "text", "text")
+		preview.Array("blocks")
+		preview.ArrayAppend(section.Data(), "blocks")
+	}
+	return blockKitBuilderURL + url.PathEscape(preview.String()), nil
+}
+
+func dryRun(format, hook, payload string) error {
+	printer, ok := dryFormats[format]
+	if !ok {
+		return fmt.Errorf("unknown dry format %v, valid formats are %v", format, strings.Join(dryFormatNames(), ", "))
+	}
+	out, err := printer(hook, payload)
+	if err != nil {
+		return err
+	}
+	fmt.Println(out)
+	return nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"os/exec"
+	"testing"
+)
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		want string
+	}{
+		{"empty", "", "''"},
+		{"plain", "hello", "'hello'"},
+		{"single quotes", "it's", `'it'\''s'`},
+		{"shell chars", "$HOME `id` \"x\" \\n", "'$HOME `id` \"x\" \\n'"},
+		{"newlines", "a\nb", "'a\nb'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := shellQuote(tt.s)
+			if got != tt.want {
+				t.Errorf("shellQuote() = %q, want %q", got, tt.want)
+			}
+			// the shell gets back the same string
+			out, err := exec.Command("sh", "-c", "printf '%s' "+got).Output()
+			if err != nil {
+				t.Fatalf("sh error = %v", err)
+			}
+			if string(out) != tt.s {
+				t.Errorf("sh printed %q, want %q", out, tt.s)
+			}
+		})
+	}
+}
+
+func TestDryCommands(t *testing.T) {
+	hook := "https://hooks.slack.com/services/T0/B0/x'y"
+	payload := `{"text":"it's $HOME"}`
+	tests := []struct {
+		name    string
+		printer func(hook, payload string) (string, error)
+		want    string
+	}{
+		{"curl", toCurl, `curl -X POST -H 'Content-type: application/json' 'https://hooks.slack.com/services/T0/B0/x'\''y' --data '{` + "\n" + `  "text": "it'\''s $HOME"` + "\n}'"},
+		{"httpie", toHttpie, `printf '%s' '{` + "\n" + `  "text": "it'\''s $HOME"` + "\n" + `}' | http POST 'https://hooks.slack.com/services/T0/B0/x'\''y' 'Content-type:application/json'`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.printer(hook, payload)
+			if err != nil {
+				t.Fatalf("error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
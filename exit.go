@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+
+	"github.com/theist/slatemess/slatemess"
+)
+
+// exit codes per failure class
+const (
+	exitOK = iota
+	exitFailure
+	exitConfig
+	exitInput
+	exitTemplate
+	exitValidation
+	exitTransport
+	exitRejected
+)
+
+var exitClasses = map[int]string{
+	exitFailure:    "failure",
+	exitConfig:     "config",
+	exitInput:      "input",
+	exitTemplate:   "template",
+	exitValidation: "validation",
+	exitTransport:  "transport",
+	exitRejected:   "rejected",
+}
+
+// an error with the exit code it should end with
+type cliError struct {
+	code int
+	msg  string
+	err  error
+}
+
+func (e *cliError) Error() string {
+	if e.msg == "" {
+		return e.err.Error()
+	}
+	return e.msg + ": " + e.err.Error()
+}
+
+func (e *cliError) Unwrap() error {
+	return e.err
+}
+
+// Sets the exit code for an error, unless it already has one
+func withCode(code int, msg string, err error) error {
+	var cliErr *cliError
+	if err == nil || errors.As(err, &cliErr) {
+		return err
+	}
+	return &cliError{code, msg, err}
+}
+
+func exitCode(err error) int {
+	var cliErr *cliError
+	var templateErr *slatemess.TemplateError
+	var payloadErr *slatemess.PayloadError
+	var transportErr *slatemess.TransportError
+	var slackErr *slatemess.SlackError
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.As(err, &cliErr):
+		return cliErr.code
+	case errors.As(err, &templateErr):
+		return exitTemplate
+	case errors.As(err, &payloadErr):
+		return exitValidation
+	case errors.As(err, &slackErr):
+		return exitRejected
+	case errors.As(err, &transportErr):
+		return exitTransport
+	}
+	return exitFailure
+}
+
+// outcome of a run, printed by -output json
+type result struct {
+	Status      string `json:"status"`
+	Target      string `json:"target,omitempty"`
+	Channel     string `json:"channel,omitempty"`
+	TS          string `json:"ts,omitempty"`
+	ScheduledID string `json:"scheduled_id,omitempty"`
+	PostAt      string `json:"post_at,omitempty"`
+	Attempts    int    `json:"attempts"`
+	Spool       string `json:"spool,omitempty"`
+	Class       string `json:"class,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// Where the message goes, the channel or the hook host as the rest of the
+// hook url is a secret
+func target(c config) string {
+	if c.channel != "" {
+		return c.channel
+	}
+	u, err := url.Parse(c.hook)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+func outputModes() []string {
+	return []string{"text", "json"}
+}
+
+// Reports the result and exits with the code for the error, if any
+func exit(output string, res result, err error) {
+	os.Exit(report(output, res, err))
+}
+
+// Reports the result, returns the exit code for the error, if any
+func report(output string, res result, err error) int {
+	code := exitCode(err)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR %v\n", err)
+		res.Class = exitClasses[code]
+		res.Error = err.Error()
+		if res.Status == "" {
+			res.Status = "failed"
+		}
+	}
+	if output == "json" {
+		out, _ := json.Marshal(res)
+		fmt.Println(string(out))
+	}
+	return code
+}
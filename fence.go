@@ -0,0 +1,76 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// -fence, a bool flag that also takes a title, as -fence=title
+type fenceFlag struct {
+	on    bool
+	title string
+}
+
+func (f *fenceFlag) String() string {
+	if f == nil || !f.on {
+		return "false"
+	}
+	if f.title != "" {
+		return f.title
+	}
+	return "true"
+}
+
+// Takes the bool values flag does, like 1 or TRUE, anything else is a
+// title
+func (f *fenceFlag) Set(value string) error {
+	if on, err := strconv.ParseBool(value); err == nil {
+		f.on, f.title = on, ""
+		return nil
+	}
+	f.on, f.title = true, value
+	return nil
+}
+
+func (f *fenceFlag) IsBoolFlag() bool {
+	return true
+}
+
+func addFenceFlag(fs *flag.FlagSet) *fenceFlag {
+	f := &fenceFlag{}
+	fs.Var(f, "fence", "Embed the rendered text in a code fence, so it will be displayed as a code block. -fence=title adds a title, like the language")
+	return f
+}
+
+// Wraps text in a code fence. Fences in the text, or backticks next to the
+// ones added, would end the block, a zero width space breaks them.
+func fenceIt(text, title string) string {
+	for strings.Contains(text, "```") {
+		text = strings.ReplaceAll(text, "```", "`\u200b``")
+	}
+	if strings.HasPrefix(text, "`") {
+		text = "\u200b" + text
+	}
+	if strings.HasSuffix(text, "`") {
+		text += "\u200b"
+	}
+	fenced := "```" + text + "```"
+	if title != "" {
+		fenced = "*" + title + "*\n" + fenced
+	}
+	return fenced
+}
+
+// fence template function: {{ fence .OUTPUT }}, or with a title
+// {{ fence "title" .OUTPUT }} or {{ .OUTPUT | fence "title" }}
+func fenceFunc(args ...string) (string, error) {
+	switch len(args) {
+	case 1:
+		return fenceIt(args[0], ""), nil
+	case 2:
+		return fenceIt(args[1], args[0]), nil
+	}
+	return "", fmt.Errorf("fence needs the text, and optionally a title first")
+}
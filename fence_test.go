@@ -0,0 +1,61 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFenceIt(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		title string
+		want  string
+	}{
+		{"plain", "make test", "", "```make test```"},
+		{"title", "ok", "go", "*go*\n```ok```"},
+		{"embedded fence", "a\n```\nb", "", "```a\n`\u200b``\nb```"},
+		{"fence at the end", "code```", "", "```code`\u200b``\u200b```"},
+		{"longer backtick runs", "a````b", "", "```a`\u200b`\u200b``b```"},
+		{"backticks next to the fences", "`x`", "", "```\u200b`x`\u200b```"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := fenceIt(tt.text, tt.title)
+			if got != tt.want {
+				t.Errorf("fenceIt() = %q, want %q", got, tt.want)
+			}
+			// only the fences added remain, with no backticks next to them
+			inner := strings.TrimSuffix(got[strings.Index(got, "```")+3:], "```")
+			if strings.Contains(inner, "```") || strings.HasPrefix(inner, "`") || strings.HasSuffix(inner, "`") {
+				t.Errorf("fenceIt() = %q, has a fence inside", got)
+			}
+		})
+	}
+}
+
+func TestFenceFlag(t *testing.T) {
+	tests := []struct {
+		value string
+		on    bool
+		title string
+	}{
+		{"true", true, ""},
+		{"1", true, ""},
+		{"TRUE", true, ""},
+		{"false", false, ""},
+		{"0", false, ""},
+		{"go", true, "go"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			f := &fenceFlag{}
+			if err := f.Set(tt.value); err != nil {
+				t.Fatalf("Set() error = %v", err)
+			}
+			if f.on != tt.on || f.title != tt.title {
+				t.Errorf("Set(%q) = %v %q, want %v %q", tt.value, f.on, f.title, tt.on, tt.title)
+			}
+		})
+	}
+}
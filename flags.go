@@ -0,0 +1,307 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// flags every command talking to slack has
+type configFlags struct {
+	hook           *string
+	token          *string
+	channel        *string
+	user           *string
+	icon           *string
+	iconURL        *string
+	profile        *string
+	debug          *bool
+	timeout        *time.Duration
+	connectTimeout *time.Duration
+	output         *string
+}
+
+// flags for the commands sending or rendering messages
+type messageFlags struct {
+	*configFlags
+	message        *string
+	file           *string
+	template       *string
+	fence          *fenceFlag
+	dry            *bool
+	dryFormat      *string
+	upload         *string
+	title          *string
+	fileType       *string
+	threadTS       *string
+	overflow       *string
+	markdown       *bool
+	markdownBlocks *bool
+	rawMrkdwn      *bool
+	forceOverride  *bool
+	color          *string
+	input          *string
+	columns        *string
+	maxRows        *int
+	maxWidth       *int
+	maxFailures    *int
+	ansiMarkers    *bool
+	status         *string
+	at             *string
+	in             *string
+	tz             *string
+	dm             *string
+	ephemeralTo    *string
+	spool          *bool
+}
+
+func addConfigFlags(fs *flag.FlagSet) *configFlags {
+	return &configFlags{
+		icon:           fs.String("icon", "", "Override default icon from hook, an emoji or the url of an image"),
+		iconURL:        fs.String("icon-url", "", "Override default icon from hook with the url of an image"),
+		user:           fs.String("user", "", "Override default user from hook"),
+		channel:        fs.String("channel", "", "Override default user from hook"),
+		hook:           fs.String("hook", "", "Override Hook provided by ENV, if any"),
+		debug:          fs.Bool("debug", false, "Print debug info"),
+		token:          fs.String("token", "", "Slack bot token, needed for uploads. Overrides SLACK_TOKEN"),
+		timeout:        fs.Duration("timeout", time.Minute, "Overall time limit for sending the message, 0 for no limit"),
+		connectTimeout: fs.Duration("connect-timeout", 10*time.Second, "Time limit for connecting to slack, 0 for no limit"),
+		profile:        fs.String("profile", os.Getenv("SLATEMESS_PROFILE"), "Load settings from this profile in ~/.slatemess.d/profiles"),
+		output:         fs.String("output", "text", "Result output: "+strings.Join(outputModes(), "|")+", json prints the result for automation"),
+	}
+}
+
+func addMessageFlags(fs *flag.FlagSet) *messageFlags {
+	return &messageFlags{
+		configFlags:    addConfigFlags(fs),
+		message:        fs.String("message", "", "Provide a message by parameter"),
+		file:           fs.String("file", "", "Provide a message by file"),
+		template:       fs.String("template", "", "Provide a message by template name, from ~/.slatemess.d/templates"),
+		fence:          addFenceFlag(fs),
+		dry:            fs.Bool("dry", false, "Will not send the payload to slack but print a curl command equivalent, with the computed payload"),
+		upload:         fs.String("upload", "", "Upload a file to -channel, the message will be used as its comment"),
+		title:          fs.String("title", "", "Title for uploaded files"),
+		fileType:       fs.String("filetype", "", "File type for uploaded files, like text, go or diff"),
+		threadTS:       fs.String("thread-ts", "", "Share uploaded files in the thread of this message ts"),
+		overflow:       fs.String("overflow", "send", "What to do with text messages too long for slack: "+strings.Join(overflowModes(), "|")),
+		markdown:       fs.Bool("markdown", false, "Convert the message from markdown to slack mrkdwn"),
+		markdownBlocks: fs.Bool("markdown-blocks", false, "Convert the message from markdown to slack blocks (implies -markdown)"),
+		rawMrkdwn:      fs.Bool("raw-mrkdwn", false, "Don't escape &, < and > in text messages, to send mrkdwn markup like <@U123> or <url|text>"),
+		forceOverride:  fs.Bool("force-override", false, "Replace the channel, username and icon a json payload already has with the configured ones"),
+		input:          fs.String("input", "", "Read the message as data instead of a template: "+strings.Join(inputModeNames(), "|")),
+		columns:        fs.String("columns", "", "Columns to show from -input tables, comma separated names or positions"),
+		maxRows:        fs.Int("max-rows", 50, "Rows to show from -input tables, 0 for all"),
+		maxWidth:       fs.Int("max-width", 40, "Max width of the cells of -input tables, 0 for no limit"),
+		maxFailures:    fs.Int("max-failures", 5, "Failures to show from -input test reports, 0 for all"),
+		ansiMarkers:    fs.Bool("ansi-markers", false, "Start the lines of piped or run output printed in red or green with an emoji"),
+		color:          fs.String("color", "", "Send the message in an attachment with this sidebar color: good, warning, danger or an hex color"),
+		status:         fs.String("status", "", "Send the message in an attachment colored for the status: "+strings.Join(statusNames(), "|")),
+		at:             fs.String("at", "", "Schedule the message for this time, like 2026-11-01T09:00, needs a bot token and a channel"),
+		in:             fs.String("in", "", "Schedule the message for after this time, like 2h or 1d12h, needs a bot token and a channel"),
+		tz:             fs.String("tz", "", "Time zone for -at, like Europe/Madrid, the local one by default"),
+		dm:             fs.String("dm", "", "Send the message as a direct message to this user, an email or user id, needs a bot token"),
+		ephemeralTo:    fs.String("ephemeral-to", "", "Send the message to -channel visible only to this user, an email or user id, needs a bot token"),
+		spool:          fs.Bool("spool", false, "Keep messages that couldn't be delivered in the spool, to be sent later"),
+		dryFormat:      fs.String("dry-format", "", "Output format for -dry: "+strings.Join(dryFormatNames(), "|")+" (implies -dry, default curl)"),
+	}
+}
+
+// Checks a command taking no arguments got none. A bool flag followed by a
+// value, like -fence title, ends the flags there and the rest would be lost.
+func noArgs(args []string) error {
+	if len(args) == 0 {
+		return nil
+	}
+	return withCode(exitConfig, "", fmt.Errorf("unexpected argument %v, a -fence title goes as -fence=title", args[0]))
+}
+
+// The output mode, valid even if the flag isn't so errors can be reported
+func (f *configFlags) outputMode() string {
+	if !stringIn(*f.output, outputModes()) {
+		return "text"
+	}
+	return *f.output
+}
+
+// Builds the config from the flags, the env and the config files
+func (f *configFlags) config() (config, error) {
+	var cfg config
+	if !stringIn(*f.output, outputModes()) {
+		return cfg, withCode(exitConfig, "", fmt.Errorf("unknown output %v, valid outputs are %v", *f.output, strings.Join(outputModes(), ", ")))
+	}
+	if !*f.debug {
+		logDebug.SetOutput(ioutil.Discard)
+	}
+	// -profile defaults to the env one, it's a flag only if it differs
+	profileFlag := *f.profile
+	if profileFlag == os.Getenv("SLATEMESS_PROFILE") {
+		profileFlag = ""
+	}
+	err := loadConfig(*f.profile, map[string]string{
+		"SLATEMESS_PROFILE": profileFlag,
+		"SLACK_ICON":        *f.icon,
+		"SLACK_ICON_URL":    *f.iconURL,
+		"SLACK_USER":        *f.user,
+		"SLACK_HOOK":        *f.hook,
+		"SLACK_CHANNEL":     *f.channel,
+		"SLACK_TOKEN":       *f.token,
+	})
+	if err != nil {
+		return cfg, withCode(exitConfig, "", err)
+	}
+	err = resolveSecretEnv()
+	if err != nil {
+		return cfg, withCode(exitConfig, "error reading secrets", err)
+	}
+
+	// once here only work with the resolved config or "message"
+	cfg.hook = configVar("SLACK_HOOK")
+	icon, iconURL := configVar("SLACK_ICON"), configVar("SLACK_ICON_URL")
+	// with both, the one from the higher layer wins, slack would always
+	// take the emoji
+	if icon != "" && iconURL != "" {
+		switch {
+		case layerRank("SLACK_ICON") < layerRank("SLACK_ICON_URL"):
+			iconURL = ""
+		case layerRank("SLACK_ICON_URL") < layerRank("SLACK_ICON"):
+			icon = ""
+		}
+	}
+	cfg.iconURL = iconURL
+	cfg.setIcon(icon)
+	cfg.channel = configVar("SLACK_CHANNEL")
+	cfg.userName = configVar("SLACK_USER")
+	cfg.token = configVar("SLACK_TOKEN")
+	cfg.profile = *f.profile
+	cfg.output = *f.output
+	cfg.timeout = *f.timeout
+	cfg.connectTimeout = *f.connectTimeout
+	cfg.network = envNetwork()
+	cfg.overflow = "send"
+	return cfg, nil
+}
+
+func templateDir() string {
+	return filepath.Join(slatemessDir(), "templates")
+}
+
+// Builds the config including the message, from -message, -file, -template
+// or, if stdin is true, piped stdin
+func (f *messageFlags) config(stdin bool) (config, error) {
+	cfg, err := f.configFlags.config()
+	if err != nil {
+		return cfg, err
+	}
+	// test report inputs read the report as the message, and the template
+	// renders it
+	template := *f.template
+	if stringIn(*f.input, reportInputs) {
+		template = ""
+	}
+	sources := 0
+	for _, source := range []string{*f.message, *f.file, template} {
+		if source != "" {
+			sources++
+		}
+	}
+	if sources > 1 {
+		return cfg, withCode(exitConfig, "", fmt.Errorf("-file, -message and -template mode are mutually exclusive"))
+	}
+	cfg.upload = *f.upload
+	cfg.uploadTitle = *f.title
+	cfg.uploadType = *f.fileType
+	cfg.threadTS = *f.threadTS
+	cfg.overflow = *f.overflow
+	cfg.rawMrkdwn = *f.rawMrkdwn
+	cfg.spool = *f.spool
+	cfg.forceOverride = *f.forceOverride
+	cfg.ansiMarkers = *f.ansiMarkers
+	if *f.markdown {
+		cfg.markdown = "mrkdwn"
+	}
+	if *f.markdownBlocks {
+		cfg.markdown = "blocks"
+	}
+	if cfg.markdown != "" {
+		// the markdown conversion does its own escaping
+		cfg.rawMrkdwn = true
+	}
+	if *f.input != "" {
+		if _, ok := inputModes[*f.input]; !ok {
+			return cfg, withCode(exitConfig, "", fmt.Errorf("unknown input %v, valid inputs are %v", *f.input, strings.Join(inputModeNames(), ", ")))
+		}
+		if cfg.markdown != "" {
+			return cfg, withCode(exitConfig, "", fmt.Errorf("-input and -markdown are mutually exclusive"))
+		}
+		// input modes escape what they need
+		cfg.input, cfg.rawMrkdwn = *f.input, false
+		cfg.table = tableOptions{maxRows: *f.maxRows, maxWidth: *f.maxWidth}
+		cfg.maxFailures = *f.maxFailures
+		if *f.template != "" && !stringIn(*f.input, reportInputs) {
+			return cfg, withCode(exitConfig, "", fmt.Errorf("-template can't be used with -input %v", *f.input))
+		}
+		for _, column := range strings.Split(*f.columns, ",") {
+			if strings.TrimSpace(column) != "" {
+				cfg.table.columns = append(cfg.table.columns, strings.TrimSpace(column))
+			}
+		}
+	}
+	if *f.status != "" {
+		color, ok := statusColors[*f.status]
+		if !ok {
+			return cfg, withCode(exitConfig, "", fmt.Errorf("unknown status %v, valid statuses are %v", *f.status, strings.Join(statusNames(), ", ")))
+		}
+		cfg.status, cfg.color = *f.status, color
+	}
+	if *f.color != "" {
+		cfg.color, err = checkColor(*f.color)
+		if err != nil {
+			return cfg, withCode(exitConfig, "", err)
+		}
+	}
+	cfg.dm, cfg.ephemeralTo = *f.dm, *f.ephemeralTo
+	cfg.postAt, err = postTime(*f.at, *f.in, *f.tz, time.Now())
+	if err != nil {
+		return cfg, withCode(exitConfig, "", err)
+	}
+	cfg.dry = *f.dry || *f.dryFormat != ""
+	cfg.dryFormat = "curl"
+	if *f.dryFormat != "" {
+		cfg.dryFormat = *f.dryFormat
+	}
+	switch {
+	case *f.message != "":
+		cfg.message = *f.message
+	case *f.file != "":
+		cfg.message, err = readFileNameAsStr(*f.file)
+	case template != "":
+		cfg.message, err = readFileNameAsStr(filepath.Join(templateDir(), template))
+	case stdin:
+		var piped bool
+		piped, err = stdinPiped()
+		if piped {
+			cfg.message = cleanTerminal(readStdin(), *f.ansiMarkers)
+		}
+	}
+	if err == nil && template == "" && *f.template != "" {
+		cfg.inputTemplate, err = readFileNameAsStr(filepath.Join(templateDir(), *f.template))
+	}
+	if err != nil {
+		return cfg, withCode(exitInput, "", err)
+	}
+	cfg.fence, cfg.fenceTitle = f.fence.on, f.fence.title
+	logDebug.Printf("Message: %#v", cfg)
+	return cfg, nil
+}
+
+func stdinPiped() (bool, error) {
+	fi, err := os.Stdin.Stat()
+	if err != nil {
+		return false, fmt.Errorf("error reading stdin: %v", err)
+	}
+	return (fi.Mode() & os.ModeCharDevice) == 0, nil
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/theist/slatemess/slatemess"
+)
+
+// How tabular input is turned into a table
+type tableOptions struct {
+	// columns to keep, by header name or 1-based position, all if empty
+	columns []string
+	// rows after the header, the rest are counted but not shown
+	maxRows int
+	// cells longer than this are cut
+	maxWidth int
+}
+
+// -input modes, each turns the message into a message to send, instead of
+// rendering it as a template
+var inputModes = map[string]func(ctx context.Context, c config, client *slatemess.Client) (string, error){
+	"json": func(_ context.Context, c config, _ *slatemess.Client) (string, error) {
+		rows, err := jsonRows(c.message)
+		if err != nil {
+			return "", err
+		}
+		return tableMessage(rows, c.table)
+	},
+	"csv": func(_ context.Context, c config, _ *slatemess.Client) (string, error) {
+		return delimitedInput(c, ',')
+	},
+	"tsv": func(_ context.Context, c config, _ *slatemess.Client) (string, error) {
+		return delimitedInput(c, '\t')
+	},
+	"diff": func(_ context.Context, c config, _ *slatemess.Client) (string, error) {
+		return diffInput(c)
+	},
+	"gotest": reportInput(goTestReport),
+	"junit":  reportInput(junitReport),
+}
+
+func inputModeNames() []string {
+	var names []string
+	for name := range inputModes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func delimitedInput(c config, comma rune) (string, error) {
+	reader := csv.NewReader(strings.NewReader(c.message))
+	reader.Comma = comma
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return "", withCode(exitInput, "error reading table", err)
+	}
+	return tableMessage(rows, c.table)
+}
+
+// Reads json as rows: an array or a stream of objects, like jq prints, an
+// array of arrays with the header first, or a single object as key and
+// value rows
+func jsonRows(text string) ([][]string, error) {
+	var values []json.RawMessage
+	decoder := json.NewDecoder(strings.NewReader(text))
+	for {
+		var value json.RawMessage
+		err := decoder.Decode(&value)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, withCode(exitInput, "error reading json", err)
+		}
+		values = append(values, value)
+	}
+	if len(values) == 1 && bytes.HasPrefix(bytes.TrimSpace(values[0]), []byte("[")) {
+		var items []json.RawMessage
+		if err := json.Unmarshal(values[0], &items); err != nil {
+			return nil, withCode(exitInput, "error reading json", err)
+		}
+		values = items
+	}
+	if len(values) == 0 {
+		return nil, nil
+	}
+	switch bytes.TrimSpace(values[0])[0] {
+	case '[':
+		var rows [][]string
+		for _, value := range values {
+			var cells []json.RawMessage
+			if err := json.Unmarshal(value, &cells); err != nil {
+				return nil, withCode(exitInput, "error reading json", err)
+			}
+			var row []string
+			for _, cell := range cells {
+				row = append(row, jsonCell(cell))
+			}
+			rows = append(rows, row)
+		}
+		return rows, nil
+	case '{':
+		var objects [][][2]string
+		var header []string
+		seen := make(map[string]bool)
+		for _, value := range values {
+			fields, err := jsonFields(value)
+			if err != nil {
+				return nil, withCode(exitInput, "error reading json", err)
+			}
+			for _, field := range fields {
+				if !seen[field[0]] {
+					seen[field[0]] = true
+					header = append(header, field[0])
+				}
+			}
+			objects = append(objects, fields)
+		}
+		if len(objects) == 1 {
+			rows := [][]string{{"key", "value"}}
+			for _, field := range objects[0] {
+				rows = append(rows, []string{field[0], field[1]})
+			}
+			return rows, nil
+		}
+		rows := [][]string{header}
+		for _, fields := range objects {
+			byKey := make(map[string]string)
+			for _, field := range fields {
+				byKey[field[0]] = field[1]
+			}
+			var row []string
+			for _, key := range header {
+				row = append(row, byKey[key])
+			}
+			rows = append(rows, row)
+		}
+		return rows, nil
+	}
+	rows := [][]string{{"value"}}
+	for _, value := range values {
+		rows = append(rows, []string{jsonCell(value)})
+	}
+	return rows, nil
+}
+
+// The keys and values of a json object, in order
+func jsonFields(object json.RawMessage) ([][2]string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(object))
+	if _, err := decoder.Token(); err != nil {
+		return nil, err
+	}
+	var fields [][2]string
+	for decoder.More() {
+		key, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return nil, err
+		}
+		fields = append(fields, [2]string{fmt.Sprint(key), jsonCell(value)})
+	}
+	return fields, nil
+}
+
+// Strings as they are, null as empty and anything else as compact json
+func jsonCell(value json.RawMessage) string {
+	var s string
+	if json.Unmarshal(value, &s) == nil {
+		return s
+	}
+	if string(value) == "null" {
+		return ""
+	}
+	var compact bytes.Buffer
+	if json.Compact(&compact, value) != nil {
+		return string(value)
+	}
+	return compact.String()
+}
+
+// Keeps the selected columns, by header name or 1-based position
+func selectColumns(rows [][]string, columns []string) ([][]string, error) {
+	if len(columns) == 0 || len(rows) == 0 {
+		return rows, nil
+	}
+	var indexes []int
+	for _, column := range columns {
+		index := -1
+		for i, name := range rows[0] {
+			if name == column {
+				index = i
+				break
+			}
+		}
+		if n, err := strconv.Atoi(column); index < 0 && err == nil && n > 0 {
+			index = n - 1
+		}
+		if index < 0 {
+			return nil, withCode(exitInput, "", fmt.Errorf("unknown column %v, the columns are %v", column, strings.Join(rows[0], ", ")))
+		}
+		indexes = append(indexes, index)
+	}
+	var selected [][]string
+	for _, row := range rows {
+		var cells []string
+		for _, index := range indexes {
+			cell := ""
+			if index < len(row) {
+				cell = row[index]
+			}
+			cells = append(cells, cell)
+		}
+		selected = append(selected, cells)
+	}
+	return selected, nil
+}
+
+// Cuts a cell to the max width, marking it was cut
+func cutCell(cell string, width int) string {
+	cell = strings.Join(strings.Fields(cell), " ")
+	runes := []rune(cell)
+	if width <= 0 || len(runes) <= width {
+		return cell
+	}
+	return string(runes[:width-1]) + "…"
+}
+
+// Renders rows, the first being the header, as section fields if it's a
+// small two column table, or else as a table in a code block
+func tableMessage(rows [][]string, opts tableOptions) (string, error) {
+	rows, err := selectColumns(rows, opts.columns)
+	if err != nil {
+		return "", err
+	}
+	if len(rows) == 0 {
+		return "", withCode(exitInput, "", fmt.Errorf("no rows in the input"))
+	}
+	hidden := 0
+	if opts.maxRows > 0 && len(rows)-1 > opts.maxRows {
+		hidden = len(rows) - 1 - opts.maxRows
+		rows = rows[:opts.maxRows+1]
+	}
+	for _, row := range rows {
+		for i := range row {
+			row[i] = cutCell(row[i], opts.maxWidth)
+		}
+	}
+	// plain text messages are escaped later, payloads aren't
+	text := fenceIt(alignRows(rows), "")
+	if hidden > 0 {
+		text += fmt.Sprintf("\n_and %d more rows_", hidden)
+	}
+	if len(rows[0]) != 2 || len(rows)*2 > maxFields {
+		return text, nil
+	}
+	var fields []interface{}
+	for r, row := range rows {
+		for _, cell := range row {
+			cell = slatemess.EscapeMrkdwn(cell)
+			if r == 0 {
+				cell = "*" + cell + "*"
+			}
+			if cell == "" {
+				cell = " "
+			}
+			fields = append(fields, map[string]interface{}{"type": "mrkdwn", "text": cell})
+		}
+	}
+	blocks := []interface{}{map[string]interface{}{"type": "section", "fields": fields}}
+	if hidden > 0 {
+		blocks = append(blocks, map[string]interface{}{
+			"type":     "context",
+			"elements": []interface{}{map[string]interface{}{"type": "mrkdwn", "text": fmt.Sprintf("and %d more rows", hidden)}},
+		})
+	}
+	payload, err := slatemess.MarshalPayload(map[string]interface{}{"text": slatemess.EscapeMrkdwn(text), "blocks": blocks})
+	if err != nil {
+		return "", withCode(exitValidation, "error building table", err)
+	}
+	return payload, nil
+}
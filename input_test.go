@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestJSONRows(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  [][]string
+		err   bool
+	}{
+		{
+			"array of objects",
+			`[{"name": "a", "n": 1}, {"name": "b", "extra": true}]`,
+			[][]string{{"name", "n", "extra"}, {"a", "1", ""}, {"b", "", "true"}},
+			false,
+		},
+		{
+			"stream of objects",
+			"{\"b\": \"x\", \"a\": null}\n{\"b\": \"y\", \"a\": [1, 2]}\n",
+			[][]string{{"b", "a"}, {"x", ""}, {"y", "[1,2]"}},
+			false,
+		},
+		{
+			"single object",
+			`{"host": "web1", "load": 0.5, "tags": {"env": "prod"}}`,
+			[][]string{{"key", "value"}, {"host", "web1"}, {"load", "0.5"}, {"tags", `{"env":"prod"}`}},
+			false,
+		},
+		{
+			"array of arrays",
+			`[["a", "b"], [1, "two"]]`,
+			[][]string{{"a", "b"}, {"1", "two"}},
+			false,
+		},
+		{
+			"array of values",
+			`["x", 2]`,
+			[][]string{{"value"}, {"x"}, {"2"}},
+			false,
+		},
+		{"empty array", `[]`, nil, false},
+		{"invalid", `{"a": `, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := jsonRows(tt.input)
+			if tt.err {
+				if exitCode(err) != exitInput {
+					t.Errorf("jsonRows() error = %v, want an input error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("jsonRows() error = %v", err)
+			}
+			if fmt.Sprintf("%q", got) != fmt.Sprintf("%q", tt.want) {
+				t.Errorf("jsonRows() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTableMessage(t *testing.T) {
+	rows := func() [][]string {
+		return [][]string{{"name", "status", "note"}, {"web1", "ok", "a <b>"}, {"db1", "down", "a long note to cut"}}
+	}
+	tests := []struct {
+		name string
+		rows [][]string
+		opts tableOptions
+		want string
+	}{
+		{"all", rows(), tableOptions{}, "```name  status  note\n----  ------  ------------------\nweb1  ok      a <b>\ndb1   down    a long note to cut```"},
+		{"columns by name and position", rows(), tableOptions{columns: []string{"note", "1", "status"}}, "```note                name  status\n------------------  ----  ------\na <b>               web1  ok\na long note to cut  db1   down```"},
+		{"max rows and width", rows(), tableOptions{maxRows: 1, maxWidth: 3}, "```na…  st…  no…\n---  ---  ---\nwe…  ok   a …```\n_and 1 more rows_"},
+		{"fences in cells", [][]string{{"a", "b", "c"}, {"```", "x", "`y`"}}, tableOptions{}, "```a    b  c\n---  -  ---\n`\u200b``  x  `y`\u200b```"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tableMessage(tt.rows, tt.opts)
+			if err != nil {
+				t.Fatalf("tableMessage() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("tableMessage() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTableMessageFields(t *testing.T) {
+	got, err := tableMessage([][]string{{"key", "value"}, {"a", "<b>"}}, tableOptions{})
+	if err != nil {
+		t.Fatalf("tableMessage() error = %v", err)
+	}
+	if !strings.Contains(got, `"fields"`) || !strings.Contains(got, `&lt;b&gt;`) {
+		t.Errorf("tableMessage() = %v, want escaped section fields", got)
+	}
+	_, err = tableMessage([][]string{{"a"}}, tableOptions{columns: []string{"missing"}})
+	if exitCode(err) != exitInput {
+		t.Errorf("tableMessage() error = %v, want an input error for an unknown column", err)
+	}
+}
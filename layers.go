@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/joho/godotenv"
+	"github.com/mitchellh/go-homedir"
+)
+
+// A config layer, the variables it has and the ones taken from it
+type configLayer struct {
+	name string
+	// the file, for file layers
+	path  string
+	found bool
+	err   error
+	vars  map[string]string
+	used  []string
+}
+
+var (
+	// the layers of the last resolved config, highest precedence first
+	configLayers []*configLayer
+	// the layer each variable was taken from
+	varOrigins = make(map[string]string)
+	// the resolved value of each variable
+	configVars = make(map[string]string)
+)
+
+func envLayer() *configLayer {
+	layer := &configLayer{name: "env", found: true, vars: make(map[string]string)}
+	for _, env := range os.Environ() {
+		split := strings.SplitN(env, "=", 2)
+		layer.vars[split[0]] = split[1]
+	}
+	return layer
+}
+
+func fileLayer(name, path string) *configLayer {
+	layer := &configLayer{name: name, path: path}
+	vars, err := godotenv.Read(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			layer.found = true
+			layer.err = err
+		}
+		return layer
+	}
+	layer.found = true
+	layer.vars = vars
+	return layer
+}
+
+// Takes each variable from the first layer having it. The values are kept
+// in configVars, the process env isn't changed.
+func resolveLayers(layers []*configLayer) {
+	configLayers = layers
+	varOrigins = make(map[string]string)
+	configVars = make(map[string]string)
+	for _, layer := range layers {
+		var names []string
+		for name := range layer.vars {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if _, ok := varOrigins[name]; ok {
+				continue
+			}
+			configVars[name] = layer.vars[name]
+			varOrigins[name] = layer.name
+			layer.used = append(layer.used, name)
+			if settingVar(name) {
+				logDebug.Printf("%v from %v", name, layer.name)
+			}
+		}
+	}
+}
+
+// Resolves the config from, in precedence order, the flags, the env, the
+// profile, if any, and the config files
+func loadConfig(profile string, flags map[string]string) error {
+	set := make(map[string]string)
+	for name, value := range flags {
+		if value != "" {
+			set[name] = value
+		}
+	}
+	layers := []*configLayer{{name: "flags", found: true, vars: set}, envLayer()}
+	if profile != "" {
+		layer := fileLayer("profile "+profile, profileFile(profile))
+		if !layer.found {
+			return fmt.Errorf("error loading profile %v: %v not found", profile, layer.path)
+		}
+		if layer.err != nil {
+			return fmt.Errorf("error loading profile %v: %v", profile, layer.err)
+		}
+		layers = append(layers, layer)
+	}
+	layers = append(layers, fileLayer(".env", ".env"))
+	homeConfigPath, err := homedir.Expand("~/.slatemess")
+	if err == nil {
+		layers = append(layers, fileLayer("~/.slatemess", homeConfigPath))
+	}
+	layers = append(layers, fileLayer("/etc/slatemess.cfg", "/etc/slatemess.cfg"))
+	layers = append(layers, fileLayer("/etc/slack.cfg", "/etc/slack.cfg"))
+	resolveLayers(layers)
+	return nil
+}
+
+// The resolved value of a variable, the env one if the config isn't loaded
+func lookupVar(name string) (string, bool) {
+	if value, ok := configVars[name]; ok {
+		return value, true
+	}
+	return os.LookupEnv(name)
+}
+
+func configVar(name string) string {
+	value, _ := lookupVar(name)
+	return value
+}
+
+// The position of the layer a variable came from, lower is higher
+// precedence
+func layerRank(name string) int {
+	for i, layer := range configLayers {
+		if layer.name == varOrigins[name] {
+			return i
+		}
+	}
+	return len(configLayers)
+}
+
+// Whether a variable is a slatemess setting
+func settingVar(name string) bool {
+	return strings.HasPrefix(name, "SLACK_") || strings.HasPrefix(name, "SLATEMESS_")
+}
+
+// Whether a variable is worth tracing: settings, and anything not coming
+// from the env
+func tracedVar(name string) bool {
+	return settingVar(name) || varOrigins[name] != "env"
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestResolveLayers(t *testing.T) {
+	defer func(layers []*configLayer, origins, vars map[string]string) {
+		configLayers, varOrigins, configVars = layers, origins, vars
+	}(configLayers, varOrigins, configVars)
+	layers := []*configLayer{
+		{name: "flags", found: true, vars: map[string]string{"SLACK_CHANNEL": "#flag"}},
+		{name: "env", found: true, vars: map[string]string{"SLACK_CHANNEL": "#env", "SLACK_USER": "env-user"}},
+		{name: "profile ops", found: true, vars: map[string]string{"SLACK_USER": "ops", "SLACK_ICON": ":ops:"}},
+		{name: ".env", found: true, vars: map[string]string{"SLACK_ICON": ":dot:", "SLACK_HOOK": "https://dot", "OTHER": "x"}},
+		{name: "~/.slatemess", found: false},
+	}
+	resolveLayers(layers)
+	tests := []struct {
+		name   string
+		value  string
+		origin string
+	}{
+		{"SLACK_CHANNEL", "#flag", "flags"},
+		{"SLACK_USER", "env-user", "env"},
+		{"SLACK_ICON", ":ops:", "profile ops"},
+		{"SLACK_HOOK", "https://dot", ".env"},
+		{"OTHER", "x", ".env"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := configVar(tt.name); got != tt.value {
+				t.Errorf("configVar() = %q, want %q", got, tt.value)
+			}
+			if varOrigins[tt.name] != tt.origin {
+				t.Errorf("varOrigins = %q, want %q", varOrigins[tt.name], tt.origin)
+			}
+			if _, ok := os.LookupEnv(tt.name); ok && tt.origin != "env" {
+				t.Errorf("%v from %v was set in the env", tt.name, tt.origin)
+			}
+		})
+	}
+	if got := layers[1].used; len(got) != 1 || got[0] != "SLACK_USER" {
+		t.Errorf("env layer used = %v, want [SLACK_USER]", got)
+	}
+	if layerRank("SLACK_ICON") >= layerRank("SLACK_HOOK") {
+		t.Errorf("layerRank(SLACK_ICON) = %d, want lower than layerRank(SLACK_HOOK) = %d", layerRank("SLACK_ICON"), layerRank("SLACK_HOOK"))
+	}
+}
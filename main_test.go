@@ -0,0 +1,17 @@
+package main
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"testing"
+
+	"github.com/theist/slatemess/slatemess"
+)
+
+func TestMain(m *testing.M) {
+	// set up by main, quiet in tests
+	logDebug = log.New(ioutil.Discard, "", 0)
+	slatemess.Debug = logDebug
+	os.Exit(m.Run())
+}
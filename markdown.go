@@ -0,0 +1,324 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/theist/slatemess/slatemess"
+)
+
+// kinds of markdown chunks
+const (
+	mdText = iota
+	mdHeading
+	mdCode
+	mdTable
+	mdRule
+)
+
+// slack limits for header texts and section fields
+const (
+	maxHeaderLength = 150
+	maxFields       = 10
+)
+
+type mdChunk struct {
+	kind  int
+	lines []string
+}
+
+var (
+	mdHeadingRe   = regexp.MustCompile(`^#{1,6}\s+(.*?)\s*#*\s*$`)
+	mdRuleRe      = regexp.MustCompile(`^\s*([-*_])(\s*([-*_])){2,}\s*$`)
+	mdFenceRe     = regexp.MustCompile("^\\s*(```|~~~)")
+	mdTableSepRe  = regexp.MustCompile(`^\s*\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?\s*$`)
+	mdBulletRe    = regexp.MustCompile(`^(\s*)[-*+]\s+(\[[ xX]\]\s+)?`)
+	mdCodeSpanRe  = regexp.MustCompile("`[^`]+`")
+	mdImageRe     = regexp.MustCompile(`!\[([^\]]*)\]\(([^)\s]+)(\s+"[^"]*")?\)`)
+	mdLinkRe      = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)(\s+"[^"]*")?\)`)
+	mdBoldRe      = regexp.MustCompile(`(\*\*|__)(\S(.*?\S)?)(\*\*|__)`)
+	mdItalicRe    = regexp.MustCompile(`(^|[^*\w])\*(\S([^*]*?\S)?)\*`)
+	mdStrikeRe    = regexp.MustCompile(`~~(\S(.*?\S)?)~~`)
+	mdBoldMarker  = "\x00"
+	mdCodeMarker  = "\x01"
+	mdTableCellRe = regexp.MustCompile(`^\s*\|?(.*?)\|?\s*$`)
+)
+
+// Splits markdown in chunks of headings, text, code, tables and rules
+func markdownChunks(markdown string) []mdChunk {
+	var chunks []mdChunk
+	lines := strings.Split(strings.TrimSpace(markdown), "\n")
+	var text []string
+	flushText := func() {
+		if len(text) > 0 {
+			chunks = append(chunks, mdChunk{mdText, text})
+			text = nil
+		}
+	}
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case mdFenceRe.MatchString(line):
+			flushText()
+			fence := mdFenceRe.FindStringSubmatch(line)[1]
+			var code []string
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), fence); i++ {
+				code = append(code, lines[i])
+			}
+			chunks = append(chunks, mdChunk{mdCode, code})
+		case mdHeadingRe.MatchString(line):
+			flushText()
+			chunks = append(chunks, mdChunk{mdHeading, []string{mdHeadingRe.FindStringSubmatch(line)[1]}})
+		case mdRuleRe.MatchString(line):
+			flushText()
+			chunks = append(chunks, mdChunk{mdRule, nil})
+		case strings.Contains(line, "|") && i+1 < len(lines) && mdTableSepRe.MatchString(lines[i+1]):
+			flushText()
+			table := []string{line}
+			for i += 2; i < len(lines) && strings.Contains(lines[i], "|"); i++ {
+				table = append(table, lines[i])
+			}
+			i--
+			chunks = append(chunks, mdChunk{mdTable, table})
+		case strings.TrimSpace(line) == "":
+			flushText()
+		default:
+			text = append(text, line)
+		}
+	}
+	flushText()
+	return chunks
+}
+
+// Converts inline markdown (emphasis, links, code spans) to mrkdwn
+func inlineMrkdwn(line string) string {
+	var spans []string
+	line = slatemess.EscapeMrkdwn(line)
+	line = mdCodeSpanRe.ReplaceAllStringFunc(line, func(span string) string {
+		spans = append(spans, span)
+		return mdCodeMarker
+	})
+	line = mdImageRe.ReplaceAllString(line, "<$2|$1>")
+	line = mdLinkRe.ReplaceAllString(line, "<$2|$1>")
+	line = mdBoldRe.ReplaceAllString(line, mdBoldMarker+"$2"+mdBoldMarker)
+	line = mdItalicRe.ReplaceAllString(line, "${1}_${2}_")
+	line = mdStrikeRe.ReplaceAllString(line, "~$1~")
+	line = strings.ReplaceAll(line, mdBoldMarker, "*")
+	for _, span := range spans {
+		line = strings.Replace(line, mdCodeMarker, span, 1)
+	}
+	return line
+}
+
+func textMrkdwn(lines []string) string {
+	var out []string
+	for _, line := range lines {
+		if m := mdBulletRe.FindStringSubmatch(line); m != nil {
+			bullet := "• "
+			switch strings.TrimSpace(m[2]) {
+			case "[ ]":
+				bullet = "☐ "
+			case "[x]", "[X]":
+				bullet = "☑ "
+			}
+			line = m[1] + bullet + line[len(m[0]):]
+		}
+		out = append(out, inlineMrkdwn(strings.TrimRight(line, " ")))
+	}
+	return strings.Join(out, "\n")
+}
+
+func tableCells(line string) []string {
+	inner := mdTableCellRe.FindStringSubmatch(line)[1]
+	var cells []string
+	for _, cell := range strings.Split(inner, "|") {
+		cells = append(cells, strings.TrimSpace(cell))
+	}
+	return cells
+}
+
+// Renders a markdown table as aligned monospace text
+func tableText(lines []string) string {
+	var rows [][]string
+	for _, line := range lines {
+		rows = append(rows, tableCells(line))
+	}
+	return alignRows(rows)
+}
+
+// Aligns the cells of each row in columns, the first row is the header
+func alignRows(rows [][]string) string {
+	var widths []int
+	for _, cells := range rows {
+		for i, cell := range cells {
+			if i >= len(widths) {
+				widths = append(widths, 0)
+			}
+			if len([]rune(cell)) > widths[i] {
+				widths[i] = len([]rune(cell))
+			}
+		}
+	}
+	var out []string
+	for r, cells := range rows {
+		var padded []string
+		for i, cell := range cells {
+			padded = append(padded, cell+strings.Repeat(" ", widths[i]-len([]rune(cell))))
+		}
+		out = append(out, strings.TrimRight(strings.Join(padded, "  "), " "))
+		if r == 0 {
+			var rule []string
+			for _, w := range widths {
+				rule = append(rule, strings.Repeat("-", w))
+			}
+			out = append(out, strings.Join(rule, "  "))
+		}
+	}
+	return strings.Join(out, "\n")
+}
+
+func codeMrkdwn(lines []string) string {
+	return fenceIt(slatemess.EscapeMrkdwn(strings.Join(lines, "\n")), "")
+}
+
+// Converts CommonMark/GFM markdown to slack mrkdwn text
+func markdownToMrkdwn(markdown string) string {
+	var out []string
+	for _, chunk := range markdownChunks(markdown) {
+		switch chunk.kind {
+		case mdHeading:
+			out = append(out, "*"+inlineMrkdwn(chunk.lines[0])+"*")
+		case mdCode:
+			out = append(out, codeMrkdwn(chunk.lines))
+		case mdTable:
+			out = append(out, codeMrkdwn([]string{tableText(chunk.lines)}))
+		case mdRule:
+			out = append(out, "──────────")
+		default:
+			out = append(out, textMrkdwn(chunk.lines))
+		}
+	}
+	return strings.Join(out, "\n\n")
+}
+
+func mrkdwnSection(text string) map[string]interface{} {
+	return map[string]interface{}{
+		"type": "section",
+		"text": map[string]interface{}{"type": "mrkdwn", "text": text},
+	}
+}
+
+// Renders lines in as many sections as needed to fit the section text limit,
+// cutting the lines too long on their own
+func mrkdwnSections(lines []string, render func([]string) string) []interface{} {
+	var sections []interface{}
+	var group []string
+	for _, line := range lines {
+		if len([]rune(render(append(group[:len(group):len(group)], line)))) <= maxSectionText {
+			group = append(group, line)
+			continue
+		}
+		if len(group) > 0 {
+			sections = append(sections, mrkdwnSection(render(group)))
+		}
+		group = []string{cutLine(line, render)}
+	}
+	if len(group) > 0 {
+		sections = append(sections, mrkdwnSection(render(group)))
+	}
+	return sections
+}
+
+// Cuts a line until it renders to a section text slack takes
+func cutLine(line string, render func([]string) string) string {
+	runes := []rune(line)
+	cut := line
+	for len(runes) > 0 {
+		over := len([]rune(render([]string{cut}))) - maxSectionText
+		if over <= 0 {
+			break
+		}
+		if over > len(runes) {
+			over = len(runes)
+		}
+		runes = runes[:len(runes)-over]
+		cut = string(runes) + "…"
+	}
+	return cut
+}
+
+// small two column tables fit in section fields, bigger ones go as code
+func tableBlocks(lines []string) []interface{} {
+	rows := len(lines)
+	if len(tableCells(lines[0])) != 2 || rows*2 > maxFields {
+		return mrkdwnSections(strings.Split(tableText(lines), "\n"), codeMrkdwn)
+	}
+	var fields []interface{}
+	for r, line := range lines {
+		for _, cell := range tableCells(line) {
+			text := inlineMrkdwn(cell)
+			if r == 0 {
+				text = "*" + text + "*"
+			}
+			if text == "" {
+				text = " "
+			}
+			fields = append(fields, map[string]interface{}{"type": "mrkdwn", "text": text})
+		}
+	}
+	return []interface{}{map[string]interface{}{"type": "section", "fields": fields}}
+}
+
+// Converts CommonMark/GFM markdown to a Block Kit payload, with the mrkdwn
+// conversion as fallback text. Markdown needing more blocks than slack takes
+// is converted to mrkdwn text instead, that -overflow applies to.
+func markdownToBlocks(markdown string) (string, error) {
+	var blocks []interface{}
+	for _, chunk := range markdownChunks(markdown) {
+		switch chunk.kind {
+		case mdHeading:
+			text := []rune(chunk.lines[0])
+			if len(text) > maxHeaderLength {
+				text = text[:maxHeaderLength]
+			}
+			blocks = append(blocks, map[string]interface{}{
+				"type": "header",
+				"text": map[string]interface{}{"type": "plain_text", "text": string(text)},
+			})
+		case mdCode:
+			blocks = append(blocks, mrkdwnSections(chunk.lines, codeMrkdwn)...)
+		case mdTable:
+			blocks = append(blocks, tableBlocks(chunk.lines)...)
+		case mdRule:
+			blocks = append(blocks, map[string]interface{}{"type": "divider"})
+		default:
+			blocks = append(blocks, mrkdwnSections(chunk.lines, textMrkdwn)...)
+		}
+	}
+	if len(blocks) > maxBlocks {
+		fmt.Fprintf(os.Stderr, "WARN the markdown needs %d blocks, more than the %d slack takes, it's sent as mrkdwn text\n", len(blocks), maxBlocks)
+		return markdownToMrkdwn(markdown), nil
+	}
+	return slatemess.MarshalPayload(map[string]interface{}{
+		"text":   truncateText(markdownToMrkdwn(markdown)),
+		"blocks": blocks,
+	})
+}
+
+// Applies the -markdown conversion mode to a rendered message, json
+// payloads are kept as they are
+func convertMarkdown(mode, message string) (string, error) {
+	if mode != "" && slatemess.IsJSON(message) {
+		logDebug.Printf("the message is a json payload, not converted from markdown")
+		return message, nil
+	}
+	switch mode {
+	case "mrkdwn":
+		return markdownToMrkdwn(message), nil
+	case "blocks":
+		return markdownToBlocks(message)
+	}
+	return message, nil
+}
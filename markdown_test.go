@@ -0,0 +1,89 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Jeffail/gabs/v2"
+	"github.com/theist/slatemess/slatemess"
+)
+
+func TestMarkdownToMrkdwn(t *testing.T) {
+	tests := []struct {
+		name     string
+		markdown string
+		want     string
+	}{
+		{"plain", "hello world", "hello world"},
+		{"bold", "some **bold** and __bold__", "some *bold* and *bold*"},
+		{"italic", "some *italic* text", "some _italic_ text"},
+		{"bold and italic", "**bold** then *italic*", "*bold* then _italic_"},
+		{"strike", "~~gone~~", "~gone~"},
+		{"link", "see [the docs](https://example.com)", "see <https://example.com|the docs>"},
+		{"image", "![logo](https://example.com/a.png)", "<https://example.com/a.png|logo>"},
+		{"code span kept", "run `a **b** <c>`", "run `a **b** &lt;c&gt;`"},
+		{"escaped", "a < b & c > d", "a &lt; b &amp; c &gt; d"},
+		{"heading", "# Title #", "*Title*"},
+		{"bullets", "- one\n* two\n  + nested", "• one\n• two\n  • nested"},
+		{"tasks", "- [ ] todo\n- [x] done", "☐ todo\n☑ done"},
+		{"rule", "one\n\n---\n\ntwo", "one\n\n──────────\n\ntwo"},
+		{"code block", "```go\nif a < b {\n```", "```if a &lt; b {```"},
+		{"table", "| a | bb |\n|---|:--:|\n| ccc | d |", "```a    bb\n---  --\nccc  d```"},
+		{"paragraphs", "one\ntwo\n\n\nthree", "one\ntwo\n\nthree"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := markdownToMrkdwn(tt.markdown); got != tt.want {
+				t.Errorf("markdownToMrkdwn(%q) = %q, want %q", tt.markdown, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarkdownToBlocksLimits(t *testing.T) {
+	longLines := strings.Repeat(strings.Repeat("word ", 20)+"\n", 100)
+	tests := []struct {
+		name     string
+		markdown string
+		blocks   int
+		wantJSON bool
+	}{
+		{"short", "# Title\n\nsome text", 2, true},
+		{"long paragraph split", longLines, 4, true},
+		{"long code split", "```\n" + longLines + "```", 4, true},
+		{"long line cut", strings.Repeat("x", 5000), 1, true},
+		{"escaped long line cut", strings.Repeat("<", 2000), 1, true},
+		{"too many blocks", strings.Repeat("# h\n\n", 51), 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := markdownToBlocks(tt.markdown)
+			if err != nil {
+				t.Fatalf("markdownToBlocks() error = %v", err)
+			}
+			if slatemess.IsJSON(got) != tt.wantJSON {
+				t.Fatalf("markdownToBlocks() = %.80q, want json %v", got, tt.wantJSON)
+			}
+			if !tt.wantJSON {
+				return
+			}
+			if problems := validatePayload(got); len(problems) > 0 {
+				t.Errorf("markdownToBlocks() payload problems: %v", problems)
+			}
+			js, _ := gabs.ParseJSON([]byte(got))
+			if n := len(js.Path("blocks").Children()); n != tt.blocks {
+				t.Errorf("markdownToBlocks() has %d blocks, want %d", n, tt.blocks)
+			}
+		})
+	}
+}
+
+func TestConvertMarkdownJSON(t *testing.T) {
+	payload := `{"text": "**not markdown**"}`
+	for _, mode := range []string{"mrkdwn", "blocks"} {
+		got, err := convertMarkdown(mode, payload)
+		if err != nil || got != payload {
+			t.Errorf("convertMarkdown(%v) = %v, %v, want the payload unchanged", mode, got, err)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/theist/slatemess/slatemess"
+)
+
+const mentionCacheTTL = 24 * time.Hour
+
+// kinds of names resolved, also the keys in the mapping and cache files
+const (
+	mentionUsers    = "users"
+	mentionGroups   = "groups"
+	mentionChannels = "channels"
+)
+
+var (
+	slackIDRe   = regexp.MustCompile(`^[UWSCGD][A-Z0-9]{6,}$`)
+	mentionKeys = []string{mentionUsers, mentionGroups, mentionChannels}
+)
+
+type mentionCache struct {
+	Updated time.Time                    `json:"updated"`
+	IDs     map[string]map[string]string `json:"ids"`
+}
+
+// Resolves user, group and channel names to slack ids using the mapping
+// file, the cache and, if there's a token, the slack api
+type mentionResolver struct {
+	client  *slatemess.Client
+	mapping map[string]map[string]string
+	cache   mentionCache
+	dirty   bool
+}
+
+func slatemessDir() string {
+	dir, err := homedir.Expand("~/.slatemess.d")
+	if err != nil {
+		return ".slatemess.d"
+	}
+	return dir
+}
+
+func mentionsFile() string {
+	if file := configVar("SLACK_MENTIONS_FILE"); file != "" {
+		return file
+	}
+	return filepath.Join(slatemessDir(), "mentions.json")
+}
+
+func mentionCacheFile() string {
+	return filepath.Join(slatemessDir(), "cache", "mentions.json")
+}
+
+func emptyIDs() map[string]map[string]string {
+	ids := make(map[string]map[string]string)
+	for _, key := range mentionKeys {
+		ids[key] = make(map[string]string)
+	}
+	return ids
+}
+
+func loadIDs(file string, into interface{}) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(content, into)
+	if err != nil {
+		logDebug.Printf("WARN: ignoring %v: %v", file, err)
+	}
+}
+
+func newMentionResolver(client *slatemess.Client) *mentionResolver {
+	r := &mentionResolver{client: client}
+	r.mapping = emptyIDs()
+	loadIDs(mentionsFile(), &r.mapping)
+	loadIDs(mentionCacheFile(), &r.cache)
+	if r.cache.IDs == nil || time.Since(r.cache.Updated) > mentionCacheTTL {
+		r.cache = mentionCache{IDs: emptyIDs()}
+	}
+	for _, key := range mentionKeys {
+		if r.mapping[key] == nil {
+			r.mapping[key] = make(map[string]string)
+		}
+		if r.cache.IDs[key] == nil {
+			r.cache.IDs[key] = make(map[string]string)
+		}
+	}
+	return r
+}
+
+func (r *mentionResolver) saveCache() {
+	if !r.dirty {
+		return
+	}
+	r.cache.Updated = time.Now()
+	content, err := json.MarshalIndent(r.cache, "", "  ")
+	if err == nil {
+		os.MkdirAll(filepath.Dir(mentionCacheFile()), 0700)
+		err = ioutil.WriteFile(mentionCacheFile(), content, 0600)
+	}
+	if err != nil {
+		logDebug.Printf("WARN: can't save mention cache: %v", err)
+	}
+}
+
+func (r *mentionResolver) resolve(ctx context.Context, kind, name string, lookup func(context.Context, string) (string, error)) (string, error) {
+	name = strings.TrimLeft(strings.TrimSpace(name), "@#")
+	// the mapping goes first, names like DEPLOYBOT look like ids
+	if id, ok := r.mapping[kind][name]; ok {
+		return id, nil
+	}
+	if slackIDRe.MatchString(name) {
+		return name, nil
+	}
+	if id, ok := r.cache.IDs[kind][name]; ok {
+		return id, nil
+	}
+	if r.client.Token == "" {
+		return "", fmt.Errorf("can't resolve %v %v, not in %v and there's no token to look it up", kind, name, mentionsFile())
+	}
+	id, err := lookup(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	r.cache.IDs[kind][name] = id
+	r.dirty = true
+	return id, nil
+}
+
+func (r *mentionResolver) lookupUser(ctx context.Context, email string) (string, error) {
+	if !strings.Contains(email, "@") {
+		return "", fmt.Errorf("can't resolve user %v, only emails can be looked up", email)
+	}
+	js, err := r.client.Call(ctx, "users.lookupByEmail", map[string]string{"email": email})
+	if err != nil {
+		return "", err
+	}
+	id, _ := js.Path("user.id").Data().(string)
+	return id, nil
+}
+
+func (r *mentionResolver) lookupGroup(ctx context.Context, handle string) (string, error) {
+	js, err := r.client.Call(ctx, "usergroups.list", nil)
+	if err != nil {
+		return "", err
+	}
+	id := ""
+	for _, group := range js.Path("usergroups").Children() {
+		groupHandle, _ := group.Path("handle").Data().(string)
+		groupID, _ := group.Path("id").Data().(string)
+		r.cache.IDs[mentionGroups][groupHandle] = groupID
+		if groupHandle == handle {
+			id = groupID
+		}
+	}
+	r.dirty = true
+	if id == "" {
+		return "", fmt.Errorf("user group %v not found", handle)
+	}
+	return id, nil
+}
+
+func (r *mentionResolver) lookupChannel(ctx context.Context, name string) (string, error) {
+	cursor := ""
+	for {
+		js, err := r.client.Call(ctx, "conversations.list", map[string]string{
+			"types":            "public_channel,private_channel",
+			"exclude_archived": "true",
+			"limit":            "1000",
+			"cursor":           cursor,
+		})
+		if err != nil {
+			return "", err
+		}
+		for _, channel := range js.Path("channels").Children() {
+			if channel.Path("name").Data() == name {
+				id, _ := channel.Path("id").Data().(string)
+				return id, nil
+			}
+		}
+		cursor, _ = js.Path("response_metadata.next_cursor").Data().(string)
+		if cursor == "" {
+			return "", fmt.Errorf("channel %v not found", name)
+		}
+	}
+}
+
+// Template functions for mentions and channel links
+func (r *mentionResolver) funcs(ctx context.Context) template.FuncMap {
+	return template.FuncMap{
+		"mention": func(user string) (string, error) {
+			id, err := r.resolve(ctx, mentionUsers, user, r.lookupUser)
+			return slatemess.Markup("<@" + id + ">"), err
+		},
+		"group": func(group string) (string, error) {
+			id, err := r.resolve(ctx, mentionGroups, group, r.lookupGroup)
+			return slatemess.Markup("<!subteam^" + id + ">"), err
+		},
+		"channelLink": func(channel string) (string, error) {
+			id, err := r.resolve(ctx, mentionChannels, channel, r.lookupChannel)
+			return slatemess.Markup("<#" + id + ">"), err
+		},
+		"here": func() string {
+			return slatemess.Markup("<!here>")
+		},
+		"atChannel": func() string {
+			return slatemess.Markup("<!channel>")
+		},
+		"everyone": func() string {
+			return slatemess.Markup("<!everyone>")
+		},
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/theist/slatemess/slatemess"
+)
+
+func TestMentionResolve(t *testing.T) {
+	r := &mentionResolver{
+		client:  slatemess.NewClient("", ""),
+		mapping: emptyIDs(),
+		cache:   mentionCache{IDs: emptyIDs()},
+	}
+	r.mapping[mentionUsers]["DEPLOYBOT"] = "U0DEPLOY1"
+	r.mapping[mentionUsers]["alice"] = "U0ALICE01"
+	r.cache.IDs[mentionUsers]["bob@example.com"] = "U0BOB0001"
+	tests := []struct {
+		name    string
+		user    string
+		want    string
+		wantErr bool
+	}{
+		{"mapped name", "alice", "U0ALICE01", false},
+		{"mapped name with @", "@alice", "U0ALICE01", false},
+		{"mapped name looking like an id", "DEPLOYBOT", "U0DEPLOY1", false},
+		{"id", "U0123456", "U0123456", false},
+		{"cached email", "bob@example.com", "U0BOB0001", false},
+		{"unknown without token", "carol@example.com", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := r.resolve(context.Background(), mentionUsers, tt.user, r.lookupUser)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolve() error = %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("resolve() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
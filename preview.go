@@ -0,0 +1,262 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Jeffail/gabs/v2"
+)
+
+// width of the preview, fields take half of it
+const previewWidth = 60
+
+var (
+	previewCodeBlockRe      = regexp.MustCompile("(?s)```\\n?(.*?)```")
+	previewCodeRe           = regexp.MustCompile("`([^`\n]+)`")
+	previewBoldRe           = regexp.MustCompile(`(^|\W)\*([^*\n]+)\*`)
+	previewItalicRe         = regexp.MustCompile(`(^|\W)_([^_\n]+)_`)
+	previewStrikeRe         = regexp.MustCompile(`(^|\W)~([^~\n]+)~`)
+	previewLinkRe           = regexp.MustCompile(`<([^<>|]+)(\|([^<>]+))?>`)
+	previewQuoteRe          = regexp.MustCompile(`(?m)^(&gt;|>) ?`)
+	previewAttachmentColors = map[string]string{"good": "32", "warning": "33", "danger": "31"}
+)
+
+// Applies ANSI styles, if color is enabled
+type styler struct {
+	color bool
+}
+
+func (s styler) style(code, text string) string {
+	if !s.color || text == "" {
+		return text
+	}
+	return "\x1b[" + code + "m" + text + "\x1b[0m"
+}
+
+// How slack shows a <...> entity: links, mentions and specials
+func previewEntity(target, label string, s styler) string {
+	switch {
+	case strings.HasPrefix(target, "@"):
+		return s.style("1;34", target)
+	case strings.HasPrefix(target, "#"):
+		return s.style("1;34", target)
+	case strings.HasPrefix(target, "!subteam^"):
+		return s.style("1;34", "@"+strings.TrimPrefix(target, "!subteam^"))
+	case strings.HasPrefix(target, "!"):
+		return s.style("1;33", "@"+strings.TrimPrefix(target, "!"))
+	case label != "":
+		return s.style("4;34", label) + " (" + target + ")"
+	}
+	return s.style("4;34", target)
+}
+
+func unescapeMrkdwn(text string) string {
+	return strings.NewReplacer("&lt;", "<", "&gt;", ">", "&amp;", "&").Replace(text)
+}
+
+// Approximates mrkdwn formatting, code blocks are kept apart so nothing
+// inside them is formatted
+func previewMrkdwn(text string, s styler) string {
+	var out []string
+	last := 0
+	// code blocks go on their own lines
+	separate := func(text string) string {
+		if last > 0 && !strings.HasPrefix(text, "\n") {
+			text = "\n" + text
+		}
+		return text
+	}
+	for _, span := range previewCodeBlockRe.FindAllStringSubmatchIndex(text, -1) {
+		before := separate(previewInline(text[last:span[0]], s))
+		if before != "" && !strings.HasSuffix(before, "\n") {
+			before += "\n"
+		}
+		out = append(out, before)
+		var code []string
+		for _, line := range strings.Split(strings.TrimRight(text[span[2]:span[3]], "\n"), "\n") {
+			code = append(code, s.style("2", "│ ")+unescapeMrkdwn(line))
+		}
+		out = append(out, strings.Join(code, "\n"))
+		last = span[1]
+	}
+	out = append(out, separate(previewInline(text[last:], s)))
+	return strings.Trim(strings.Join(out, ""), "\n")
+}
+
+func previewInline(text string, s styler) string {
+	text = previewQuoteRe.ReplaceAllString(text, s.style("2", "▌ "))
+	text = previewLinkRe.ReplaceAllStringFunc(text, func(entity string) string {
+		m := previewLinkRe.FindStringSubmatch(entity)
+		return previewEntity(m[1], m[3], s)
+	})
+	text = previewCodeRe.ReplaceAllStringFunc(text, func(code string) string {
+		return s.style("36", strings.Trim(code, "`"))
+	})
+	for _, format := range []struct {
+		re   *regexp.Regexp
+		code string
+	}{{previewBoldRe, "1"}, {previewItalicRe, "3"}, {previewStrikeRe, "9"}} {
+		code := format.code
+		text = format.re.ReplaceAllStringFunc(text, func(match string) string {
+			m := format.re.FindStringSubmatch(match)
+			return m[1] + s.style(code, m[2])
+		})
+	}
+	return unescapeMrkdwn(text)
+}
+
+// Renders a text object, either mrkdwn or plain_text
+func previewText(js *gabs.Container, s styler) string {
+	text, _ := js.Path("text").Data().(string)
+	if js.Path("type").Data() == "plain_text" {
+		return text
+	}
+	return previewMrkdwn(text, s)
+}
+
+// Lays out section fields in two columns
+func previewFields(fields []*gabs.Container, s styler) string {
+	var lines []string
+	column := previewWidth / 2
+	for i := 0; i < len(fields); i += 2 {
+		left := strings.Split(previewText(fields[i], s), "\n")
+		var right []string
+		if i+1 < len(fields) {
+			right = strings.Split(previewText(fields[i+1], s), "\n")
+		}
+		for j := 0; j < len(left) || j < len(right); j++ {
+			l, r := "", ""
+			if j < len(left) {
+				l = left[j]
+			}
+			if j < len(right) {
+				r = right[j]
+			}
+			padding := column - len([]rune(stripANSI(l)))
+			if padding < 1 {
+				padding = 1
+			}
+			lines = append(lines, strings.TrimRight(l+strings.Repeat(" ", padding)+r, " "))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func previewBlock(block *gabs.Container, s styler) string {
+	kind, _ := block.Path("type").Data().(string)
+	switch kind {
+	case "header":
+		return s.style("1;4", previewText(block.Path("text"), s))
+	case "divider":
+		return s.style("2", strings.Repeat("─", previewWidth))
+	case "section":
+		var parts []string
+		if block.Exists("text") {
+			parts = append(parts, previewText(block.Path("text"), s))
+		}
+		if fields := block.Path("fields").Children(); len(fields) > 0 {
+			parts = append(parts, previewFields(fields, s))
+		}
+		if block.Exists("accessory") {
+			parts = append(parts, s.style("2", "["+fmt.Sprint(block.Path("accessory.type").Data())+"]"))
+		}
+		return strings.Join(parts, "\n")
+	case "context":
+		var parts []string
+		for _, element := range block.Path("elements").Children() {
+			if element.Path("type").Data() == "image" {
+				parts = append(parts, "["+fmt.Sprint(element.Path("alt_text").Data())+"]")
+				continue
+			}
+			parts = append(parts, previewText(element, s))
+		}
+		return s.style("2", strings.Join(parts, " · "))
+	case "image":
+		return s.style("2", "[image: "+fmt.Sprint(block.Path("alt_text").Data())+"]")
+	case "actions":
+		var buttons []string
+		for _, element := range block.Path("elements").Children() {
+			buttons = append(buttons, "[ "+previewText(element.Path("text"), s)+" ]")
+		}
+		return strings.Join(buttons, " ")
+	}
+	return s.style("2", "["+kind+" block]")
+}
+
+// The ANSI color for an attachment color, named or hex
+func attachmentColor(color string) string {
+	if code, ok := previewAttachmentColors[color]; ok {
+		return code
+	}
+	hex := strings.TrimPrefix(color, "#")
+	if len(hex) != 6 {
+		return "2"
+	}
+	rgb, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return "2"
+	}
+	return fmt.Sprintf("38;2;%d;%d;%d", rgb>>16, (rgb>>8)&0xff, rgb&0xff)
+}
+
+func previewAttachment(attachment *gabs.Container, s styler) string {
+	var parts []string
+	for _, key := range []string{"pretext", "title", "text"} {
+		text, _ := attachment.Path(key).Data().(string)
+		if text == "" {
+			continue
+		}
+		if key == "title" {
+			text = "*" + text + "*"
+		}
+		parts = append(parts, previewMrkdwn(text, s))
+	}
+	for _, field := range attachment.Path("fields").Children() {
+		parts = append(parts, s.style("1", fmt.Sprint(field.Path("title").Data()))+": "+previewMrkdwn(fmt.Sprint(field.Path("value").Data()), s))
+	}
+	for _, block := range attachment.Path("blocks").Children() {
+		parts = append(parts, previewBlock(block, s))
+	}
+	if footer, _ := attachment.Path("footer").Data().(string); footer != "" {
+		parts = append(parts, s.style("2", footer))
+	}
+	color, _ := attachment.Path("color").Data().(string)
+	bar := s.style(attachmentColor(color), "┃ ")
+	var lines []string
+	for _, line := range strings.Split(strings.Join(parts, "\n"), "\n") {
+		lines = append(lines, bar+line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Approximates in a terminal how slack will show a payload
+func previewPayload(payload string, color bool) string {
+	s := styler{color}
+	js, err := gabs.ParseJSON([]byte(payload))
+	if err != nil {
+		return payload + "\n"
+	}
+	var parts []string
+	var header []string
+	for _, key := range []string{"username", "icon_emoji", "icon_url", "avatar_url", "channel"} {
+		if value, _ := js.Path(key).Data().(string); value != "" {
+			header = append(header, value)
+		}
+	}
+	if len(header) > 0 {
+		parts = append(parts, s.style("2", strings.Join(header, " · ")))
+	}
+	blocks := js.Path("blocks").Children()
+	for _, block := range blocks {
+		parts = append(parts, previewBlock(block, s))
+	}
+	if text, _ := js.Path("text").Data().(string); text != "" && len(blocks) == 0 {
+		parts = append(parts, previewMrkdwn(text, s))
+	}
+	for _, attachment := range js.Path("attachments").Children() {
+		parts = append(parts, previewAttachment(attachment, s))
+	}
+	return strings.Join(parts, "\n") + "\n"
+}
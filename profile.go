@@ -0,0 +1,34 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/theist/slatemess/slatemess"
+)
+
+func profileDir() string {
+	return filepath.Join(slatemessDir(), "profiles")
+}
+
+func profileFile(name string) string {
+	return filepath.Join(profileDir(), name)
+}
+
+// Network settings from the env
+func envNetwork() slatemess.Network {
+	n := slatemess.Network{
+		Proxy:         configVar("SLACK_PROXY"),
+		ProxyUser:     configVar("SLACK_PROXY_USER"),
+		ProxyPassword: configVar("SLACK_PROXY_PASSWORD"),
+		CABundle:      configVar("SLACK_CA_BUNDLE"),
+		ClientCert:    configVar("SLACK_CLIENT_CERT"),
+		ClientKey:     configVar("SLACK_CLIENT_KEY"),
+	}
+	for _, host := range strings.Split(configVar("SLACK_ALLOWED_HOSTS"), ",") {
+		if strings.TrimSpace(host) != "" {
+			n.AllowedHosts = append(n.AllowedHosts, strings.TrimSpace(host))
+		}
+	}
+	return n
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/theist/slatemess/slatemess"
+)
+
+// message run -react posts when the command starts
+const defaultRunStartTemplate = ":hourglass_flowing_sand: `{{ .COMMAND }}` started"
+
+// reactions run -react adds when the command ends
+const (
+	reactionOK     = "white_check_mark"
+	reactionFailed = "x"
+)
+
+// Adds, or removes, a reaction to a message. Reacting twice or removing a
+// missing reaction isn't an error, so reactions can be retried.
+func react(ctx context.Context, client *slatemess.Client, channel, ts, emoji string, remove bool) error {
+	emoji = strings.Trim(emoji, ":")
+	var err error
+	if remove {
+		err = client.RemoveReaction(ctx, channel, ts, emoji)
+	} else {
+		err = client.AddReaction(ctx, channel, ts, emoji)
+	}
+	var slackErr *slatemess.SlackError
+	if errors.As(err, &slackErr) && (slackErr.Code == "already_reacted" || slackErr.Code == "no_reaction") {
+		logDebug.Printf("reaction %v: %v", emoji, slackErr.Code)
+		return nil
+	}
+	return err
+}
+
+// Reacts to the message run posted when the command started, with the
+// reaction for its exit code
+func reactToRun(c config, started result, code int) error {
+	emoji := reactionOK
+	if code != 0 {
+		emoji = reactionFailed
+	}
+	if c.dry {
+		fmt.Printf("react with :%v:\n", emoji)
+		return nil
+	}
+	client, err := newClient(c)
+	if err != nil {
+		return withCode(exitConfig, "", err)
+	}
+	ctx, stop := commandContext(c)
+	defer stop()
+	return react(ctx, client, started.Channel, started.TS, emoji, false)
+}
+
+func setupReact(fs *flag.FlagSet) func([]string) {
+	f := addConfigFlags(fs)
+	ts := fs.String("ts", "", "The ts of the message to react to")
+	emoji := fs.String("emoji", "", "The reaction, an emoji name like white_check_mark")
+	remove := fs.Bool("remove", false, "Remove the reaction instead of adding it")
+	return func(args []string) {
+		c, err := f.config()
+		if err != nil {
+			exit(f.outputMode(), result{}, err)
+		}
+		res := result{Target: target(c), Channel: c.channel, TS: *ts}
+		switch {
+		case c.token == "":
+			err = fmt.Errorf("reactions need a bot token, use -token or SLACK_TOKEN")
+		case c.channel == "":
+			err = fmt.Errorf("reactions need a channel id, use -channel or SLACK_CHANNEL")
+		case *ts == "" || strings.Trim(*emoji, ":") == "":
+			err = fmt.Errorf("reactions need the -ts of a message and an -emoji")
+		}
+		if err != nil {
+			exit(c.output, res, withCode(exitConfig, "", err))
+		}
+		client, err := newClient(c)
+		if err != nil {
+			exit(c.output, res, withCode(exitConfig, "", err))
+		}
+		ctx, stop := commandContext(c)
+		defer stop()
+		res.Attempts++
+		err = react(ctx, client, c.channel, *ts, *emoji, *remove)
+		if err == nil {
+			res.Status = "reacted"
+			if *remove {
+				res.Status = "removed"
+			}
+		}
+		stop()
+		exit(c.output, res, err)
+	}
+}
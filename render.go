@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/tidwall/pretty"
+)
+
+// Renders and completes the message as send would do, without sending it
+func buildPayload(ctx context.Context, c config) (string, error) {
+	client, err := newClient(c)
+	if err != nil {
+		return "", withCode(exitConfig, "", err)
+	}
+	message, err := renderMessage(ctx, c, client)
+	if err != nil {
+		return "", err
+	}
+	message, err = convertMarkdown(c.markdown, message)
+	if err != nil {
+		return "", withCode(exitValidation, "error converting markdown", err)
+	}
+	return messageComplete(message, c)
+}
+
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && (fi.Mode()&os.ModeCharDevice) != 0
+}
+
+func setupRender(fs *flag.FlagSet) func([]string) {
+	f := addMessageFlags(fs)
+	preview := fs.Bool("preview", false, "Print an approximation of how the message will look instead of the payload")
+	return func(args []string) {
+		c, err := f.config(true)
+		if err == nil {
+			err = noArgs(args)
+		}
+		if err != nil {
+			exit(f.outputMode(), result{}, err)
+		}
+		if c.message == "" {
+			exit(c.output, result{}, withCode(exitInput, "", fmt.Errorf("missing message")))
+		}
+		ctx, stop := signalContext()
+		defer stop()
+		payload, err := buildPayload(ctx, c)
+		stop()
+		if err != nil {
+			exit(c.output, result{}, err)
+		}
+		if *preview {
+			fmt.Print(previewPayload(payload, isTerminal(os.Stdout)))
+			return
+		}
+		fmt.Print(string(pretty.Pretty([]byte(payload))))
+	}
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/theist/slatemess/slatemess"
+)
+
+// message for test reports when there's no -template
+const defaultReportTemplate = "{{ if .FAILED }}:x:{{ else }}:white_check_mark:{{ end }} " +
+	"*{{ .PASSED }} passed, {{ .FAILED }} failed, {{ .SKIPPED }} skipped* in {{ .DURATION }}" +
+	"{{ range .FAILURES }}\n\n*{{ .Name }}*{{ if .Package }} in {{ .Package }}{{ end }}, {{ .Duration }}" +
+	"{{ if .Output }}\n{{ fence .Output }}{{ end }}{{ end }}" +
+	"{{ if .MORE_FAILURES }}\n\n_and {{ .MORE_FAILURES }} more failures_{{ end }}"
+
+// lines of output kept for each failure
+const maxFailureLines = 10
+
+type testFailure struct {
+	Name     string
+	Package  string
+	Duration string
+	Output   string
+}
+
+type testReport struct {
+	passed   int
+	failed   int
+	skipped  int
+	duration time.Duration
+	failures []testFailure
+}
+
+func roundDuration(seconds float64) string {
+	return time.Duration(seconds * float64(time.Second)).Round(10 * time.Millisecond).String()
+}
+
+// The last lines of a test output, without the lines go test adds
+func outputExcerpt(output string) string {
+	var lines []string
+	for _, line := range strings.Split(output, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "=== ") || strings.HasPrefix(trimmed, "--- ") ||
+			trimmed == "FAIL" || trimmed == "PASS" {
+			continue
+		}
+		lines = append(lines, strings.TrimRight(line, " \t"))
+	}
+	if len(lines) > maxFailureLines {
+		lines = append([]string{"…"}, lines[len(lines)-maxFailureLines:]...)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// an event of go test -json
+type testEvent struct {
+	Action  string
+	Package string
+	Test    string
+	Elapsed float64
+	Output  string
+}
+
+// Reads a go test -json stream. Build output and lines that aren't events
+// are shown with the packages failing without failed tests.
+func goTestReport(text string) (testReport, error) {
+	var report testReport
+	output := make(map[string]*strings.Builder)
+	outputOf := func(key string) *strings.Builder {
+		if output[key] == nil {
+			output[key] = &strings.Builder{}
+		}
+		return output[key]
+	}
+	var failed []testEvent
+	events := 0
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var event testEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil || event.Action == "" {
+			outputOf("").WriteString(scanner.Text() + "\n")
+			continue
+		}
+		events++
+		key := event.Package + " " + event.Test
+		switch event.Action {
+		case "output":
+			outputOf(key).WriteString(event.Output)
+		case "build-output":
+			outputOf("").WriteString(event.Output)
+		case "pass", "fail", "skip":
+			if event.Test == "" {
+				report.duration += time.Duration(event.Elapsed * float64(time.Second))
+				if event.Action == "fail" {
+					failed = append(failed, event)
+				}
+				continue
+			}
+			switch event.Action {
+			case "pass":
+				report.passed++
+			case "skip":
+				report.skipped++
+			case "fail":
+				failed = append(failed, event)
+			}
+		}
+	}
+	if events == 0 {
+		return report, withCode(exitInput, "", fmt.Errorf("no go test -json events in the input"))
+	}
+	for _, event := range failed {
+		if event.Test == "" {
+			// a package failing without failed tests didn't build or panicked
+			if packageTestsFailed(failed, event.Package) {
+				continue
+			}
+			report.failed++
+			excerpt := outputExcerpt(outputOf("").String() + outputOf(event.Package+" ").String())
+			report.failures = append(report.failures, testFailure{"package " + event.Package, "", roundDuration(event.Elapsed), excerpt})
+			continue
+		}
+		if hasFailedSubtests(failed, event) {
+			continue
+		}
+		report.failed++
+		excerpt := outputExcerpt(outputOf(event.Package + " " + event.Test).String())
+		report.failures = append(report.failures, testFailure{event.Test, event.Package, roundDuration(event.Elapsed), excerpt})
+	}
+	return report, nil
+}
+
+func packageTestsFailed(failed []testEvent, pkg string) bool {
+	for _, event := range failed {
+		if event.Package == pkg && event.Test != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// parents fail with their subtests, only the subtests are reported
+func hasFailedSubtests(failed []testEvent, parent testEvent) bool {
+	for _, event := range failed {
+		if event.Package == parent.Package && strings.HasPrefix(event.Test, parent.Test+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+type junitResult struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+type junitCase struct {
+	Name      string       `xml:"name,attr"`
+	ClassName string       `xml:"classname,attr"`
+	Time      float64      `xml:"time,attr"`
+	Failure   *junitResult `xml:"failure"`
+	Error     *junitResult `xml:"error"`
+	Skipped   *junitResult `xml:"skipped"`
+	SystemOut string       `xml:"system-out"`
+}
+
+// a testsuite or testsuites element, either can be the root
+type junitSuite struct {
+	XMLName xml.Name
+	Name    string       `xml:"name,attr"`
+	Time    float64      `xml:"time,attr"`
+	Suites  []junitSuite `xml:"testsuite"`
+	Cases   []junitCase  `xml:"testcase"`
+}
+
+func (s junitSuite) addTo(report *testReport) {
+	// testsuites time is the sum of its suites, not counted twice
+	if len(s.Cases) > 0 {
+		report.duration += time.Duration(s.Time * float64(time.Second))
+	}
+	for _, suite := range s.Suites {
+		suite.addTo(report)
+	}
+	for _, c := range s.Cases {
+		problem := c.Failure
+		if problem == nil {
+			problem = c.Error
+		}
+		switch {
+		case problem != nil:
+			report.failed++
+			output := strings.TrimSpace(problem.Message + "\n" + problem.Text)
+			if output == "" {
+				output = c.SystemOut
+			}
+			pkg := c.ClassName
+			if pkg == "" {
+				pkg = s.Name
+			}
+			report.failures = append(report.failures, testFailure{c.Name, pkg, roundDuration(c.Time), outputExcerpt(output)})
+		case c.Skipped != nil:
+			report.skipped++
+		default:
+			report.passed++
+		}
+	}
+}
+
+func junitReport(text string) (testReport, error) {
+	var report testReport
+	var root junitSuite
+	if err := xml.Unmarshal([]byte(text), &root); err != nil {
+		return report, withCode(exitInput, "error reading junit report", err)
+	}
+	if root.XMLName.Local != "testsuites" && root.XMLName.Local != "testsuite" {
+		return report, withCode(exitInput, "", fmt.Errorf("not a junit report, the root is %v", root.XMLName.Local))
+	}
+	root.addTo(&report)
+	return report, nil
+}
+
+// Renders a test report with the -template, or the default one. The
+// template gets the env and the report.
+func renderReport(ctx context.Context, c config, client *slatemess.Client, report testReport) (string, error) {
+	data := make(map[string]interface{})
+	for key, value := range dictEnviron() {
+		data[key] = value
+	}
+	failures := report.failures
+	more := 0
+	if c.maxFailures > 0 && len(failures) > c.maxFailures {
+		more = len(failures) - c.maxFailures
+		failures = failures[:c.maxFailures]
+	}
+	data["PASSED"] = report.passed
+	data["FAILED"] = report.failed
+	data["SKIPPED"] = report.skipped
+	data["TOTAL"] = report.passed + report.failed + report.skipped
+	data["DURATION"] = report.duration.Round(10 * time.Millisecond).String()
+	data["FAILURES"] = failures
+	data["MORE_FAILURES"] = more
+	tmpl := c.inputTemplate
+	if tmpl == "" {
+		tmpl = defaultReportTemplate
+	}
+	return renderTemplate(ctx, client, tmpl, data)
+}
+
+// input modes that render a report with a template
+var reportInputs = []string{"gotest", "junit"}
+
+func reportInput(parse func(string) (testReport, error)) func(context.Context, config, *slatemess.Client) (string, error) {
+	return func(ctx context.Context, c config, client *slatemess.Client) (string, error) {
+		report, err := parse(c.message)
+		if err != nil {
+			return "", err
+		}
+		return renderReport(ctx, c, client, report)
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func failureNames(report testReport) string {
+	var names []string
+	for _, f := range report.failures {
+		names = append(names, f.Package+" "+f.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+func TestGoTestReport(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		passed   int
+		failed   int
+		skipped  int
+		failures string
+		output   string
+	}{
+		{
+			name: "passing",
+			input: `{"Action":"run","Package":"p","Test":"TestA"}
+{"Action":"pass","Package":"p","Test":"TestA","Elapsed":0.1}
+{"Action":"skip","Package":"p","Test":"TestB","Elapsed":0}
+{"Action":"pass","Package":"p","Elapsed":0.5}`,
+			passed: 1, skipped: 1,
+		},
+		{
+			name: "subtests",
+			input: `{"Action":"run","Package":"p","Test":"TestA"}
+{"Action":"run","Package":"p","Test":"TestA/one"}
+{"Action":"output","Package":"p","Test":"TestA/one","Output":"=== RUN   TestA/one\n"}
+{"Action":"output","Package":"p","Test":"TestA/one","Output":"    a_test.go:10: got 1, want 2\n"}
+{"Action":"output","Package":"p","Test":"TestA/one","Output":"--- FAIL: TestA/one (0.00s)\n"}
+{"Action":"fail","Package":"p","Test":"TestA/one","Elapsed":0}
+{"Action":"pass","Package":"p","Test":"TestA/two","Elapsed":0}
+{"Action":"fail","Package":"p","Test":"TestA","Elapsed":0.01}
+{"Action":"fail","Package":"p","Elapsed":0.2}`,
+			passed: 1, failed: 1,
+			failures: "p TestA/one",
+			output:   "a_test.go:10: got 1, want 2",
+		},
+		{
+			name: "build failure",
+			input: `{"ImportPath":"q [q.test]","Action":"build-output","Output":"# q\n"}
+{"ImportPath":"q [q.test]","Action":"build-output","Output":"q/a.go:3:1: syntax error\n"}
+{"ImportPath":"q [q.test]","Action":"build-fail"}
+{"Action":"start","Package":"q"}
+{"Action":"output","Package":"q","Output":"FAIL\tq [build failed]\n"}
+{"Action":"fail","Package":"q","Elapsed":0}`,
+			failed:   1,
+			failures: " package q",
+			output:   "q/a.go:3:1: syntax error",
+		},
+		{
+			name: "plain output before the events",
+			input: `# r
+r/b.go:1:1: expected 'package'
+{"Action":"fail","Package":"r","Elapsed":0}`,
+			failed:   1,
+			failures: " package r",
+			output:   "expected 'package'",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := goTestReport(tt.input)
+			if err != nil {
+				t.Fatalf("goTestReport() error = %v", err)
+			}
+			if report.passed != tt.passed || report.failed != tt.failed || report.skipped != tt.skipped {
+				t.Errorf("goTestReport() = %d passed, %d failed, %d skipped, want %d, %d, %d",
+					report.passed, report.failed, report.skipped, tt.passed, tt.failed, tt.skipped)
+			}
+			if got := failureNames(report); got != tt.failures {
+				t.Errorf("goTestReport() failures = %q, want %q", got, tt.failures)
+			}
+			if tt.output != "" && !strings.Contains(report.failures[0].Output, tt.output) {
+				t.Errorf("goTestReport() output = %q, want it to have %q", report.failures[0].Output, tt.output)
+			}
+		})
+	}
+}
+
+func TestGoTestReportNoEvents(t *testing.T) {
+	_, err := goTestReport("ok  \tp\t0.1s\n")
+	if exitCode(err) != exitInput {
+		t.Errorf("goTestReport() error = %v, want an input error", err)
+	}
+}
+
+func TestJunitReport(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		passed   int
+		failed   int
+		skipped  int
+		duration time.Duration
+		failures string
+		output   string
+		err      bool
+	}{
+		{
+			name: "testsuites",
+			input: `<testsuites>
+  <testsuite name="a" time="1.5">
+    <testcase name="ok" classname="a.A" time="0.5"/>
+    <testcase name="bad" classname="a.A" time="1">
+      <failure message="expected 1">stack trace</failure>
+    </testcase>
+  </testsuite>
+  <testsuite name="b" time="2">
+    <testcase name="skip" classname="b.B"><skipped/></testcase>
+    <testcase name="boom" time="2"><error message="panic"/></testcase>
+  </testsuite>
+</testsuites>`,
+			passed: 1, failed: 2, skipped: 1,
+			duration: 3500 * time.Millisecond,
+			failures: "a.A bad, b boom",
+			output:   "expected 1\nstack trace",
+		},
+		{
+			name: "single testsuite",
+			input: `<?xml version="1.0"?>
+<testsuite name="s" time="0.25">
+  <testcase name="out" time="0.25"><failure/><system-out>printed</system-out></testcase>
+</testsuite>`,
+			failed:   1,
+			duration: 250 * time.Millisecond,
+			failures: "s out",
+			output:   "printed",
+		},
+		{
+			name: "nested suites",
+			input: `<testsuites><testsuite name="outer"><testsuite name="inner" time="1">
+  <testcase name="ok"/>
+</testsuite></testsuite></testsuites>`,
+			passed:   1,
+			duration: time.Second,
+		},
+		{name: "not junit", input: `<html></html>`, err: true},
+		{name: "not xml", input: `{"a": 1}`, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := junitReport(tt.input)
+			if tt.err {
+				if exitCode(err) != exitInput {
+					t.Errorf("junitReport() error = %v, want an input error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("junitReport() error = %v", err)
+			}
+			if report.passed != tt.passed || report.failed != tt.failed || report.skipped != tt.skipped {
+				t.Errorf("junitReport() = %d passed, %d failed, %d skipped, want %d, %d, %d",
+					report.passed, report.failed, report.skipped, tt.passed, tt.failed, tt.skipped)
+			}
+			if report.duration != tt.duration {
+				t.Errorf("junitReport() duration = %v, want %v", report.duration, tt.duration)
+			}
+			if got := failureNames(report); got != tt.failures {
+				t.Errorf("junitReport() failures = %q, want %q", got, tt.failures)
+			}
+			if tt.output != "" && report.failures[0].Output != tt.output {
+				t.Errorf("junitReport() output = %q, want %q", report.failures[0].Output, tt.output)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// message for run when there's no -message, -file or -template
+const defaultRunTemplate = "{{ if eq .EXIT_CODE \"0\" }}:white_check_mark:{{ else }}:x:{{ end }} " +
+	"`{{ .COMMAND }}` exited with {{ .EXIT_CODE }} after {{ .DURATION }}" +
+	"{{ if .OUTPUT }}\n{{ fence .OUTPUT }}{{ end }}"
+
+// collects the output of a command, safe to be written from stdout and
+// stderr at the same time
+type outputBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (o *outputBuffer) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.buf.Write(p)
+}
+
+func (o *outputBuffer) String() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.buf.String()
+}
+
+// Runs a command passing its output thru, returns the output and the exit
+// code of the command
+func execCommand(args []string) (string, int, error) {
+	var output outputBuffer
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = io.MultiWriter(os.Stdout, &output)
+	cmd.Stderr = io.MultiWriter(os.Stderr, &output)
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return output.String(), exitErr.ExitCode(), nil
+	}
+	if err != nil {
+		return "", 0, fmt.Errorf("error running %v: %v", args[0], err)
+	}
+	return output.String(), 0, nil
+}
+
+func setupRun(fs *flag.FlagSet) func([]string) {
+	f := addMessageFlags(fs)
+	failures := fs.Bool("failures", false, "Send the message only if the command fails")
+	reactions := fs.Bool("react", false, "Send the message when the command starts, and react to it when it ends instead of sending another one")
+	return func(args []string) {
+		if len(args) == 0 {
+			exit(f.outputMode(), result{}, withCode(exitConfig, "", fmt.Errorf("missing command to run")))
+		}
+		c, err := f.config(false)
+		if err != nil {
+			exit(f.outputMode(), result{}, err)
+		}
+		if *reactions {
+			if *failures {
+				exit(c.output, result{}, withCode(exitConfig, "", fmt.Errorf("-react and -failures are mutually exclusive")))
+			}
+			runReacting(c, args)
+		}
+		start := time.Now()
+		output, code, err := execCommand(args)
+		if err != nil {
+			exit(c.output, result{}, withCode(exitInput, "", err))
+		}
+		if *failures && code == 0 {
+			os.Exit(0)
+		}
+		c.data = map[string]string{
+			"COMMAND":   strings.Join(args, " "),
+			"EXIT_CODE": strconv.Itoa(code),
+			"DURATION":  time.Since(start).Round(time.Millisecond).String(),
+			"OUTPUT":    strings.TrimSpace(cleanTerminal(output, c.ansiMarkers)),
+		}
+		if c.message == "" {
+			c.message = defaultRunTemplate
+		}
+		ctx, stop := signalContext()
+		defer stop()
+		res, err := send(ctx, c)
+		stop()
+		sendCode := report(c.output, res, err)
+		if err == nil {
+			os.Exit(code)
+		}
+		os.Exit(sendCode)
+	}
+}
+
+// Runs the command for run -react: sends the message when it starts and
+// reacts to it when it ends
+func runReacting(c config, args []string) {
+	c.api = true
+	c.data = map[string]string{"COMMAND": strings.Join(args, " ")}
+	if c.message == "" {
+		c.message = defaultRunStartTemplate
+	}
+	ctx, stop := signalContext()
+	res, err := send(ctx, c)
+	stop()
+	if exitCode(err) == exitConfig {
+		exit(c.output, res, err)
+	}
+	if err != nil {
+		// the command runs anyway, with nothing to react to
+		fmt.Fprintf(os.Stderr, "WARN the start message couldn't be sent, there will be no reaction\n")
+	}
+	_, code, runErr := execCommand(args)
+	if runErr != nil {
+		exit(c.output, res, withCode(exitInput, "", runErr))
+	}
+	if err == nil {
+		err = reactToRun(c, res, code)
+	}
+	sendCode := report(c.output, res, err)
+	if err == nil {
+		os.Exit(code)
+	}
+	os.Exit(sendCode)
+}
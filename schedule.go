@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/theist/slatemess/slatemess"
+)
+
+// layouts accepted by -at, besides RFC 3339 with the time zone offset
+var atLayouts = []string{
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+}
+
+var daysRe = regexp.MustCompile(`^(\d+)d`)
+
+// The time zone for -at and the scheduled list, the local one by default
+func timeZone(name string) (*time.Location, error) {
+	if name == "" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone %v: %v", name, err)
+	}
+	return loc, nil
+}
+
+// Parses -in, a duration that can start with days, like 1d12h
+func parseIn(in string) (time.Duration, error) {
+	var days time.Duration
+	rest := in
+	if m := daysRe.FindStringSubmatch(in); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		days = time.Duration(n) * 24 * time.Hour
+		rest = in[len(m[0]):]
+	}
+	if rest == "" {
+		return days, nil
+	}
+	d, err := time.ParseDuration(rest)
+	if err != nil {
+		return 0, fmt.Errorf("invalid -in %v, use a duration like 2h, 90m or 1d12h", in)
+	}
+	return days + d, nil
+}
+
+// The time to post a message from -at or -in, zero for sending it now
+func postTime(at, in, tz string, now time.Time) (time.Time, error) {
+	if at != "" && in != "" {
+		return time.Time{}, fmt.Errorf("-at and -in are mutually exclusive")
+	}
+	if in != "" {
+		d, err := parseIn(in)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return now.Add(d), nil
+	}
+	if at == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, at); err == nil {
+		return t, nil
+	}
+	loc, err := timeZone(tz)
+	if err != nil {
+		return time.Time{}, err
+	}
+	for _, layout := range atLayouts {
+		if t, err := time.ParseInLocation(layout, at, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid -at %v, use a time like 2026-11-01T09:00", at)
+}
+
+// Checks the post time is in the range slack accepts
+func verifyPostTime(postAt, now time.Time) error {
+	if !postAt.After(now) {
+		return fmt.Errorf("can't schedule at %v, it's in the past", postAt.Format(time.RFC3339))
+	}
+	if postAt.Sub(now) > slatemess.MaxScheduleAhead {
+		return fmt.Errorf("can't schedule at %v, slack allows up to %d days ahead", postAt.Format(time.RFC3339), int(slatemess.MaxScheduleAhead.Hours()/24))
+	}
+	return nil
+}
+
+// Schedules the payload, or prints it with -dry
+func scheduleMessage(ctx context.Context, client *slatemess.Client, c config, payload string, res *result) error {
+	res.PostAt = c.postAt.Format(time.RFC3339)
+	if c.dry {
+		fmt.Printf("schedule at %v to channel %v\n%v\n", res.PostAt, c.channel, prettyPayload(payload))
+		return nil
+	}
+	res.Attempts++
+	id, err := client.Schedule(ctx, payload, c.postAt)
+	if err != nil {
+		return err
+	}
+	res.ScheduledID = id
+	return nil
+}
+
+func listScheduled(ctx context.Context, client *slatemess.Client, c config, loc *time.Location) error {
+	scheduled, err := client.ScheduledMessages(ctx, c.channel)
+	if err != nil {
+		return err
+	}
+	if c.output == "json" {
+		var list []map[string]string
+		for _, s := range scheduled {
+			list = append(list, map[string]string{"id": s.ID, "channel": s.Channel, "post_at": s.PostAt.In(loc).Format(time.RFC3339), "text": s.Text})
+		}
+		out, _ := json.Marshal(list)
+		fmt.Println(string(out))
+		return nil
+	}
+	for _, s := range scheduled {
+		text := strings.Join(strings.Fields(s.Text), " ")
+		fmt.Printf("%v  %v  %v  %v\n", s.ID, s.Channel, s.PostAt.In(loc).Format("2006-01-02 15:04 MST"), cutCell(text, 60))
+	}
+	return nil
+}
+
+// Deletes scheduled messages, looking for their channel if there's no
+// -channel
+func deleteScheduled(ctx context.Context, client *slatemess.Client, c config, ids []string) error {
+	channels := make(map[string]string)
+	if c.channel == "" {
+		scheduled, err := client.ScheduledMessages(ctx, "")
+		if err != nil {
+			return err
+		}
+		for _, s := range scheduled {
+			channels[s.ID] = s.Channel
+		}
+	}
+	for _, id := range ids {
+		channel := c.channel
+		if channel == "" {
+			channel = channels[id]
+		}
+		if channel == "" {
+			return withCode(exitInput, "", fmt.Errorf("no scheduled message %v", id))
+		}
+		if err := client.DeleteScheduled(ctx, channel, id); err != nil {
+			return err
+		}
+		if c.output != "json" {
+			fmt.Printf("deleted %v\n", id)
+		}
+	}
+	return nil
+}
+
+func setupScheduled(fs *flag.FlagSet) func([]string) {
+	f := addConfigFlags(fs)
+	tz := fs.String("tz", "", "Time zone to show the times in, like Europe/Madrid, the local one by default")
+	return func(args []string) {
+		c, err := f.config()
+		if err != nil {
+			exit(f.outputMode(), result{}, err)
+		}
+		res := result{Target: target(c)}
+		if len(args) == 0 || !stringIn(args[0], []string{"list", "delete"}) || (args[0] == "delete" && len(args) < 2) {
+			exit(c.output, res, withCode(exitConfig, "", fmt.Errorf("use scheduled list, or scheduled delete id...")))
+		}
+		if c.token == "" {
+			exit(c.output, res, withCode(exitConfig, "", fmt.Errorf("scheduled messages need a bot token, use -token or SLACK_TOKEN")))
+		}
+		loc, err := timeZone(*tz)
+		if err != nil {
+			exit(c.output, res, withCode(exitConfig, "", err))
+		}
+		client, err := newClient(c)
+		if err != nil {
+			exit(c.output, res, withCode(exitConfig, "", err))
+		}
+		ctx, stop := commandContext(c)
+		defer stop()
+		switch args[0] {
+		case "list":
+			err = listScheduled(ctx, client, c, loc)
+			if c.output == "json" {
+				// the list is the output
+				os.Exit(report("text", res, err))
+			}
+		case "delete":
+			err = deleteScheduled(ctx, client, c, args[1:])
+			if err == nil {
+				res.Status = "deleted"
+			}
+		}
+		stop()
+		exit(c.output, res, err)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseIn(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"2h", 2 * time.Hour, false},
+		{"90m", 90 * time.Minute, false},
+		{"1d", 24 * time.Hour, false},
+		{"1d12h", 36 * time.Hour, false},
+		{"10d30m", 240*time.Hour + 30*time.Minute, false},
+		{"", 0, false},
+		{"d", 0, true},
+		{"1x", 0, true},
+		{"1d2", 0, true},
+		{"h1d", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := parseIn(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseIn() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil && !strings.Contains(err.Error(), "invalid -in "+tt.in) {
+				t.Errorf("parseIn() error = %v, want it to name the input", err)
+			}
+			if got != tt.want {
+				t.Errorf("parseIn() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPostTime(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		at      string
+		in      string
+		tz      string
+		want    string
+		wantErr string
+	}{
+		{"now", "", "", "", "0001-01-01T00:00:00Z", ""},
+		{"in", "", "1d2h", "", "2026-10-20T14:00:00Z", ""},
+		{"rfc 3339", "2026-11-01T09:00:00+01:00", "", "", "2026-11-01T09:00:00+01:00", ""},
+		{"layout in a zone", "2026-11-01 09:00", "", "Europe/Madrid", "2026-11-01T09:00:00+01:00", ""},
+		{"layout in summer time", "2026-07-01T09:00", "", "Europe/Madrid", "2026-07-01T09:00:00+02:00", ""},
+		{"both", "2026-11-01T09:00", "1h", "", "", "mutually exclusive"},
+		{"bad at", "tomorrow", "", "", "", "invalid -at"},
+		{"bad zone", "2026-11-01T09:00", "", "Nowhere/City", "", "unknown time zone"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := postTime(tt.at, tt.in, tt.tz, now)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("postTime() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("postTime() error = %v", err)
+			}
+			if got.Format(time.RFC3339) != tt.want {
+				t.Errorf("postTime() = %v, want %v", got.Format(time.RFC3339), tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyPostTime(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		postAt  time.Time
+		wantErr string
+	}{
+		{"soon", now.Add(time.Minute), ""},
+		{"at the limit", now.Add(120 * 24 * time.Hour), ""},
+		{"now", now, "in the past"},
+		{"past", now.Add(-time.Hour), "in the past"},
+		{"too far", now.Add(121 * 24 * time.Hour), "up to 120 days"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifyPostTime(tt.postAt, now)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("verifyPostTime() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("verifyPostTime() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"strings"
+)
+
+// env variables that can hold a secret reference instead of the value
+var secretVars = []string{"SLACK_HOOK", "SLACK_TOKEN", "SLACK_PROXY_PASSWORD"}
+
+// variables holding a secret for an env: reference, kept out of templates
+// as the secret variables are
+var secretRefs = make(map[string]bool)
+
+// max references followed, so env:A -> env:B -> env:A doesn't loop forever
+const maxSecretDepth = 8
+
+// layers whose cmd: references aren't run, as anyone can drop a .env in a
+// repository
+var untrustedLayers = []string{".env"}
+
+// Resolves the secret reference in the value of a variable. Values can be
+// given as:
+//   - file:/path/to/file reads the file contents
+//   - cmd:command args runs the command with sh -c and uses its output,
+//     unless the variable comes from an untrusted layer
+//   - env:OTHER_VAR uses the value of another env variable
+//
+// Any other value is returned as is. The resolved value is trimmed.
+func resolveSecret(name, value string) (string, error) {
+	for depth := 0; depth < maxSecretDepth; depth++ {
+		var err error
+		switch {
+		case strings.HasPrefix(value, "file:"):
+			value, err = secretFromFile(strings.TrimPrefix(value, "file:"))
+		case strings.HasPrefix(value, "cmd:"):
+			if stringIn(varOrigins[name], untrustedLayers) {
+				return "", fmt.Errorf("cmd: references aren't run from %v, set %v in the env, a profile or ~/.slatemess", varOrigins[name], name)
+			}
+			value, err = secretFromCmd(strings.TrimPrefix(value, "cmd:"))
+		case strings.HasPrefix(value, "env:"):
+			name = strings.TrimPrefix(value, "env:")
+			secretRefs[name] = true
+			value, err = secretFromEnv(name)
+		default:
+			return value, nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return "", fmt.Errorf("too many nested references (max %d)", maxSecretDepth)
+}
+
+// Whether a variable holds a secret, so it isn't given to templates
+func secretVar(name string) bool {
+	return stringIn(name, secretVars) || secretRefs[name]
+}
+
+func secretFromFile(path string) (string, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("error reading secret file: %v", err)
+	}
+	return strings.TrimSpace(string(content)), nil
+}
+
+func secretFromCmd(command string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if err != nil {
+		return "", fmt.Errorf("error running secret command %q: %v %v", command, err, strings.TrimSpace(stderr.String()))
+	}
+	secret := strings.TrimSpace(stdout.String())
+	if secret == "" {
+		return "", fmt.Errorf("secret command %q returned nothing", command)
+	}
+	return secret, nil
+}
+
+func secretFromEnv(name string) (string, error) {
+	value, ok := lookupVar(name)
+	if !ok {
+		return "", fmt.Errorf("secret env variable %v is not set", name)
+	}
+	return value, nil
+}
+
+// Replaces secret references in the config with their values
+func resolveSecretEnv() error {
+	for _, name := range secretVars {
+		value, ok := lookupVar(name)
+		if !ok {
+			continue
+		}
+		secret, err := resolveSecret(name, value)
+		if err != nil {
+			return fmt.Errorf("error resolving %v: %v", name, err)
+		}
+		if secret != value {
+			logDebug.Printf("%v resolved from %v", name, value)
+		}
+		configVars[name] = secret
+	}
+	return nil
+}
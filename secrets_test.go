@@ -0,0 +1,84 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolveSecret(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "hook")
+	if err := ioutil.WriteFile(file, []byte("  https://hooks.slack.com/x\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	defer func(vars, origins map[string]string) {
+		configVars, varOrigins = vars, origins
+	}(configVars, varOrigins)
+	configVars = map[string]string{
+		"PLAIN":    "value",
+		"TO_FILE":  "file:" + file,
+		"LOOP_A":   "env:LOOP_B",
+		"LOOP_B":   "env:LOOP_A",
+		"DOTENV":   "cmd:echo from dotenv",
+		"TO_DOT":   "env:DOTENV",
+		"PROFILED": "cmd:echo from profile",
+	}
+	varOrigins = map[string]string{"DOTENV": ".env", "PROFILED": "profile ops"}
+	tests := []struct {
+		name    string
+		varName string
+		value   string
+		want    string
+		wantErr string
+	}{
+		{"plain value", "SLACK_HOOK", "https://hooks.slack.com/x", "https://hooks.slack.com/x", ""},
+		{"file", "SLACK_HOOK", "file:" + file, "https://hooks.slack.com/x", ""},
+		{"missing file", "SLACK_HOOK", "file:" + filepath.Join(dir, "missing"), "", "error reading secret file"},
+		{"cmd", "SLACK_HOOK", "cmd:printf ' tok\\n'", "tok", ""},
+		{"failing cmd", "SLACK_HOOK", "cmd:echo nope >&2; exit 3", "", "nope"},
+		{"empty cmd output", "SLACK_HOOK", "cmd:true", "", "returned nothing"},
+		{"env", "SLACK_HOOK", "env:PLAIN", "value", ""},
+		{"env to file", "SLACK_HOOK", "env:TO_FILE", "https://hooks.slack.com/x", ""},
+		{"missing env", "SLACK_HOOK", "env:MISSING_SECRET_VAR", "", "MISSING_SECRET_VAR is not set"},
+		{"loop", "SLACK_HOOK", "env:LOOP_A", "", "too many nested references"},
+		{"cmd from .env", "DOTENV", "cmd:echo from dotenv", "", "aren't run from .env"},
+		{"env to cmd from .env", "SLACK_HOOK", "env:TO_DOT", "", "aren't run from .env"},
+		{"cmd from a profile", "PROFILED", "cmd:echo from profile", "from profile", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveSecret(tt.varName, tt.value)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("resolveSecret() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolveSecret() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("resolveSecret() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDictEnvironSecrets(t *testing.T) {
+	defer func(vars map[string]string, refs map[string]bool) {
+		configVars, secretRefs = vars, refs
+	}(configVars, secretRefs)
+	configVars = map[string]string{"SLACK_TOKEN": "xoxb-1", "SLACK_HOOK": "https://x", "HOLDER": "xoxb-2", "SLACK_CHANNEL": "#ops"}
+	secretRefs = map[string]bool{"HOLDER": true}
+	dict := dictEnviron()
+	for _, name := range []string{"SLACK_TOKEN", "SLACK_HOOK", "HOLDER"} {
+		if _, ok := dict[name]; ok {
+			t.Errorf("dictEnviron() has %v", name)
+		}
+	}
+	if dict["SLACK_CHANNEL"] != "#ops" {
+		t.Errorf("dictEnviron() SLACK_CHANNEL = %q, want #ops", dict["SLACK_CHANNEL"])
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/theist/slatemess/slatemess"
+)
+
+// limit for posted messages
+const maxServeBody = 1 << 20
+
+// time limits for serve requests, the write one is the time to send the
+// message plus this margin, or serveSendLimit with no -timeout
+const (
+	serveHeaderTimeout = 10 * time.Second
+	serveReadTimeout   = 30 * time.Second
+	serveSendLimit     = 5 * time.Minute
+)
+
+// http status for each failure class
+var serveStatus = map[int]int{
+	exitConfig:     http.StatusInternalServerError,
+	exitInput:      http.StatusBadRequest,
+	exitTemplate:   http.StatusBadRequest,
+	exitValidation: http.StatusBadRequest,
+	exitTransport:  http.StatusBadGateway,
+	exitRejected:   http.StatusBadGateway,
+}
+
+// Renders a posted message. The template gets the env as messages sent do,
+// but without the slatemess variables, as anyone able to post could read
+// them.
+func renderServed(ctx context.Context, c config, client *slatemess.Client) (string, error) {
+	data := templateData(c.data)
+	for name := range data {
+		if strings.HasPrefix(name, "SLATEMESS_") {
+			delete(data, name)
+		}
+	}
+	return renderTemplate(ctx, client, c.message, data)
+}
+
+// Whether the address only listens on the loopback interface
+func loopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// Handles posted messages, the body is the message template, and channel,
+// user and icon can be overridden by query params
+func serveHandler(c config, token string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "only POST is allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if token != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxServeBody))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		msg := c
+		msg.message = string(body)
+		msg.renderer = renderServed
+		query := r.URL.Query()
+		if channel := query.Get("channel"); channel != "" {
+			msg.channel = channel
+		}
+		if user := query.Get("user"); user != "" {
+			msg.userName = user
+		}
+		if icon := query.Get("icon"); icon != "" {
+			msg.icon, msg.iconURL = "", ""
+			msg.setIcon(icon)
+		}
+		res, err := send(r.Context(), msg)
+		status := http.StatusOK
+		if err != nil {
+			logDebug.Printf("serve: %v", err)
+			code := exitCode(err)
+			res.Status = "failed"
+			res.Class = exitClasses[code]
+			res.Error = err.Error()
+			status = serveStatus[code]
+			if status == 0 {
+				status = http.StatusInternalServerError
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(res)
+	}
+}
+
+func setupServe(fs *flag.FlagSet) func([]string) {
+	f := addMessageFlags(fs)
+	listen := fs.String("listen", "127.0.0.1:8080", "Address to listen on")
+	return func(args []string) {
+		c, err := f.config(false)
+		if err == nil {
+			err = noArgs(args)
+		}
+		if err != nil {
+			exit(f.outputMode(), result{}, err)
+		}
+		token := configVar("SLATEMESS_SERVE_TOKEN")
+		if token == "" && !loopbackAddr(*listen) {
+			exit(c.output, result{}, withCode(exitConfig, "", fmt.Errorf("serving on %v needs SLATEMESS_SERVE_TOKEN, only loopback addresses can be used without it", *listen)))
+		}
+		writeTimeout := serveSendLimit
+		if c.timeout > 0 {
+			writeTimeout = c.timeout + serveReadTimeout
+		}
+		server := &http.Server{
+			Addr:              *listen,
+			Handler:           serveHandler(c, token),
+			ReadHeaderTimeout: serveHeaderTimeout,
+			ReadTimeout:       serveReadTimeout,
+			WriteTimeout:      writeTimeout,
+		}
+		fmt.Fprintf(os.Stderr, "listening on %v\n", *listen)
+		err = server.ListenAndServe()
+		exit(c.output, result{}, withCode(exitConfig, "error serving", err))
+	}
+}
@@ -2,31 +2,72 @@ package main
 
 import (
 	"bufio"
-	"bytes"
-	"encoding/json"
-	"flag"
+	"context"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"net/url"
 	"os"
+	"os/signal"
+	"sort"
 	"strings"
+	"syscall"
 	"text/template"
+	"time"
 
-	"github.com/Jeffail/gabs/v2"
-	"github.com/joho/godotenv"
-	"github.com/mitchellh/go-homedir"
-	"github.com/tidwall/pretty"
-	"gopkg.in/resty.v1"
+	"github.com/theist/slatemess/slatemess"
 )
 
 type config struct {
-	hook     string
-	icon     string
-	userName string
-	channel  string
-	message  string
-	dry      bool
+	hook          string
+	icon          string
+	iconURL       string
+	userName      string
+	channel       string
+	message       string
+	dry           bool
+	dryFormat     string
+	token         string
+	upload        string
+	uploadTitle   string
+	uploadType    string
+	threadTS      string
+	overflow      string
+	markdown      string
+	rawMrkdwn     bool
+	forceOverride bool
+	ansiMarkers   bool
+	// -fence, and its title if any
+	fence      bool
+	fenceTitle string
+	// -input mode, and how tables are rendered
+	input string
+	table tableOptions
+	// template and failures shown for test report inputs
+	inputTemplate string
+	maxFailures   int
+	// attachment sidebar color and status, if any
+	color  string
+	status string
+	// when to post the message, zero to send it now
+	postAt time.Time
+	// user to send the message to, directly or in the channel
+	dm          string
+	ephemeralTo string
+	// post with the Web API even to a channel, to get the message ts back
+	api            bool
+	timeout        time.Duration
+	connectTimeout time.Duration
+	spool          bool
+	network        slatemess.Network
+	profile        string
+	output         string
+	// template data besides the env, like the output of run
+	data map[string]string
+	// renders the message instead of the template, for commands with
+	// their own template data, like digest
+	renderer func(context.Context, config, *slatemess.Client) (string, error)
 }
 
 var logDebug *log.Logger
@@ -41,35 +82,88 @@ func readStdin() string {
 	return text
 }
 
-func fenceIt(text string) string {
-	return "```" + text + "```"
-}
-
 func (c config) verifyConfig() error {
-	if c.message == "" {
-		return fmt.Errorf("missing message")
+	if c.message == "" && c.upload == "" {
+		return withCode(exitInput, "", fmt.Errorf("missing message"))
 	}
-	u, err := url.Parse(c.hook)
-	if err != nil {
-		return fmt.Errorf("error in url %v: %v", c.hook, err)
+	if !stringIn(c.overflow, overflowModes()) {
+		return fmt.Errorf("unknown overflow mode %v, valid modes are %v", c.overflow, strings.Join(overflowModes(), ", "))
+	}
+	if c.upload != "" || c.overflow == "upload" {
+		if c.token == "" {
+			return fmt.Errorf("uploads need a bot token, use -token or SLACK_TOKEN")
+		}
+		if c.channel == "" && c.dm == "" {
+			return fmt.Errorf("uploads need a channel id, use -channel or SLACK_CHANNEL")
+		}
+	}
+	if c.dm != "" || c.ephemeralTo != "" {
+		if c.dm != "" && c.ephemeralTo != "" {
+			return fmt.Errorf("-dm and -ephemeral-to are mutually exclusive")
+		}
+		if c.token == "" {
+			return fmt.Errorf("direct and ephemeral messages need a bot token, use -token or SLACK_TOKEN")
+		}
+		if c.ephemeralTo != "" && c.channel == "" {
+			return fmt.Errorf("ephemeral messages need a channel id, use -channel or SLACK_CHANNEL")
+		}
+		if c.ephemeralTo != "" && (c.upload != "" || c.overflow == "upload" || !c.postAt.IsZero()) {
+			return fmt.Errorf("ephemeral messages can't be uploads or scheduled")
+		}
+		if c.spool {
+			return fmt.Errorf("direct and ephemeral messages can't be spooled")
+		}
+	}
+	if c.api {
+		if c.token == "" {
+			return fmt.Errorf("reacting to messages needs a bot token, use -token or SLACK_TOKEN")
+		}
+		if c.channel == "" && c.dm == "" {
+			return fmt.Errorf("reacting to messages needs a channel id, use -channel or SLACK_CHANNEL")
+		}
+		if c.ephemeralTo != "" || c.upload != "" || c.overflow == "upload" || !c.postAt.IsZero() || c.spool {
+			return fmt.Errorf("messages to react to can't be ephemeral, uploads, scheduled or spooled")
+		}
+	}
+	if !c.postAt.IsZero() {
+		if c.token == "" {
+			return fmt.Errorf("scheduled messages need a bot token, use -token or SLACK_TOKEN")
+		}
+		if c.channel == "" && c.dm == "" {
+			return fmt.Errorf("scheduled messages need a channel id, use -channel or SLACK_CHANNEL")
+		}
+		if c.upload != "" || c.overflow == "upload" {
+			return fmt.Errorf("uploads can't be scheduled")
+		}
+		if c.spool {
+			return fmt.Errorf("scheduled messages can't be spooled")
+		}
+		if err := verifyPostTime(c.postAt, time.Now()); err != nil {
+			return err
+		}
 	}
-	if u.Scheme != "https" {
-		return fmt.Errorf("invalid hook, invalid scheme %v", u.Scheme)
+	if c.upload == "" && !c.viaAPI() {
+		u, err := url.Parse(c.hook)
+		if err != nil {
+			return fmt.Errorf("error in url %v: %v", c.hook, err)
+		}
+		if u.Scheme != "https" {
+			return fmt.Errorf("invalid hook, invalid scheme %v", u.Scheme)
+		}
+	}
+	if _, ok := dryFormats[c.dryFormat]; c.dry && !ok {
+		return fmt.Errorf("unknown dry format %v, valid formats are %v", c.dryFormat, strings.Join(dryFormatNames(), ", "))
 	}
 	return nil
 }
 
-func isJSON(s string) bool {
-	var js map[string]interface{}
-
-	ugly := pretty.Ugly([]byte(s))
-	logDebug.Printf("checking json for: %v", string(ugly))
-	err := json.Unmarshal(ugly, &js)
-	if err != nil {
-		logDebug.Printf("Isn't json because: %v", err)
-		return false
+func stringIn(s string, list []string) bool {
+	for _, item := range list {
+		if s == item {
+			return true
+		}
 	}
-	return true
+	return false
 }
 
 // checks string for problematic chars
@@ -83,115 +177,231 @@ func forbiddenVal(s string) bool {
 	return false
 }
 
-// Returns Env as map key value
+// Returns Env as map key value, with the variables of the resolved config
+// and without the secrets
 func dictEnviron() map[string]string {
-	dict := make(map[string]string)
+	vars := make(map[string]string)
 	for _, env := range os.Environ() {
 		split := strings.SplitN(env, "=", 2)
-		if forbiddenVal(split[1]) {
+		vars[split[0]] = split[1]
+	}
+	for name, value := range configVars {
+		vars[name] = value
+	}
+	// references not resolved yet, as in collect, point to secrets too
+	refs := make(map[string]bool)
+	for _, name := range secretVars {
+		if strings.HasPrefix(vars[name], "env:") {
+			refs[strings.TrimPrefix(vars[name], "env:")] = true
+		}
+	}
+	dict := make(map[string]string)
+	for name, value := range vars {
+		if forbiddenVal(value) || secretVar(name) || refs[name] {
 			continue
 		}
-		dict[split[0]] = split[1]
+		dict[name] = value
 	}
 	return dict
 }
 
-// Renders a message doing env sustitution
-func messageRender(message string) (string, error) {
-	var render bytes.Buffer
-	t, err := template.New("message").Parse(message)
-	if err != nil {
-		return "", fmt.Errorf("error rendering slack template: %v", err)
+// The env with data added, for templates
+func templateData(data map[string]string) map[string]string {
+	dict := dictEnviron()
+	for key, value := range data {
+		dict[key] = value
 	}
-	err = t.Execute(&render, dictEnviron())
-	if err != nil {
-		return "", fmt.Errorf("error generating slack payload: %v", err)
+	return dict
+}
+
+// Renders a message doing env sustitution, data is added to the env
+func messageRender(message string, data map[string]string, funcs template.FuncMap) (string, error) {
+	return slatemess.Render(message, templateData(data), funcs)
+}
+
+// Renders the message template of the config
+func renderMessage(ctx context.Context, c config, client *slatemess.Client) (string, error) {
+	if c.input != "" {
+		return inputModes[c.input](ctx, c, client)
+	}
+	var message string
+	var err error
+	if c.renderer != nil {
+		message, err = c.renderer(ctx, c, client)
+	} else {
+		message, err = renderTemplate(ctx, client, c.message, templateData(c.data))
 	}
-	return render.String(), nil
+	if err != nil || !c.fence {
+		return message, err
+	}
+	if slatemess.IsJSON(message) {
+		fmt.Fprintf(os.Stderr, "WARN -fence ignored, the message is a json payload\n")
+		return message, nil
+	}
+	return fenceIt(message, c.fenceTitle), nil
+}
+
+// The functions for message templates, the mention helpers and fence
+func templateFuncs(ctx context.Context, mentions *mentionResolver) template.FuncMap {
+	funcs := mentions.funcs(ctx)
+	funcs["fence"] = fenceFunc
+	return funcs
 }
 
-func messageSafe(message string) string {
-	safe := strings.TrimSpace(message)
-	safe = strings.ReplaceAll(safe, "\n", `\n`)
-	safe = strings.ReplaceAll(safe, `"`, `\"`)
-	return safe
+// Renders a template with the template functions, caching the mentions
+// looked up
+func renderTemplate(ctx context.Context, client *slatemess.Client, tmpl string, data interface{}) (string, error) {
+	mentions := newMentionResolver(client)
+	message, err := slatemess.Render(tmpl, data, templateFuncs(ctx, mentions))
+	mentions.saveCache()
+	return message, err
 }
 
-func hasKey(key string, js *gabs.Container) bool {
-	return js.Exists(key)
+// the env variable behind each payload field the config can set
+var payloadFieldVars = map[string]string{
+	"channel":    "SLACK_CHANNEL",
+	"username":   "SLACK_USER",
+	"icon_emoji": "SLACK_ICON",
+	"icon_url":   "SLACK_ICON_URL",
 }
 
 func messageComplete(message string, c config) (string, error) {
-	msg := ""
-	if isJSON(message) {
-		msg = message
-	} else {
-		msg = `{ "text": "` + messageSafe(message) + `" }`
+	msg := slatemess.NewMessage(message).
+		Channel(c.channel).
+		Username(c.userName).
+		Icon(c.icon).
+		IconURL(c.iconURL).
+		RawMrkdwn(c.rawMrkdwn).
+		Override(c.forceOverride)
+	if c.dm != "" {
+		// a direct message can't end in the channel of the payload
+		msg.ForceChannel(c.channel)
+	}
+	ignored := msg.Ignored()
+	var fields []string
+	for field := range ignored {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		value := ignored[field]
+		if origin := varOrigins[payloadFieldVars[field]]; origin != "" {
+			value += " from " + origin
+		}
+		fmt.Fprintf(os.Stderr, "WARN the payload sets %v, %v is ignored, use -force-override to replace it\n", field, value)
 	}
-	js, err := gabs.ParseJSON([]byte(msg))
+	payload, err := msg.Payload()
 	if err != nil {
 		return "", err
 	}
-	if c.channel != "" {
-		if hasKey("channel", js) {
-			logDebug.Printf("WARN: channel in the payload, your specified channel %v won't be used", c.channel)
-		} else {
-			js.Set(c.channel, "channel")
-		}
-	}
-	if c.userName != "" {
-		if hasKey("username", js) {
-			logDebug.Printf("WARN: username in the payload, your specified username %v won't be used", c.userName)
-		} else {
-			js.Set(c.userName, "username")
+	if c.color != "" {
+		payload, err = attachPayload(payload, c.color, c.status)
+		if err != nil {
+			return "", err
 		}
 	}
-	if c.icon != "" {
-		if hasKey("icon_emoji", js) {
-			logDebug.Printf("WARN: icon_emoji in the payload, your specified icon %v won't be used", c.icon)
-		} else {
-			js.Set(c.icon, "icon_emoji")
-		}
+	if c.viaAPI() {
+		// posted to slack, not the hook
+		return payload, nil
 	}
-
-	logDebug.Printf("gabs object +%v", js)
-	return js.String(), nil
+	return backendPayload(c.hook, payload)
 }
 
-func toSlack(hook, payload string) error {
-	res, err := resty.R().
-		SetBody(payload).
-		Post(hook)
-	if err != nil {
-		return fmt.Errorf("error sending message %v", err)
+func newClient(c config) (*slatemess.Client, error) {
+	client := slatemess.NewClient(c.hook, c.token)
+	if c.connectTimeout > 0 {
+		client.SetConnectTimeout(c.connectTimeout)
 	}
-	if res.IsError() {
-		return fmt.Errorf("slack api returned an error %v \"%v\"", res.Status(), string(res.Body()))
+	err := client.SetNetwork(c.network)
+	if err != nil {
+		return nil, fmt.Errorf("error in network settings: %v", err)
 	}
-	return nil
+	return client, nil
 }
 
-func toCurl(hook, payload string) {
-	fmt.Println("curl -X POST -H 'Content-type: application/json' " + hook + " --data '" + strings.TrimSpace(string(pretty.Pretty([]byte(payload)))) + "'")
+// Reports a payload that couldn't be delivered, spooling it to be sent
+// later if it didn't reach slack and -spool is set
+func undelivered(c config, payload string, err error, res *result) error {
+	var transportErr *slatemess.TransportError
+	if !c.spool || !errors.As(err, &transportErr) {
+		return err
+	}
+	file, spoolErr := spoolPayload(c, payload, err)
+	if spoolErr != nil {
+		return withCode(exitTransport, "message undelivered", fmt.Errorf("%v, and it couldn't be spooled: %v", err, spoolErr))
+	}
+	res.Status = "spooled"
+	res.Spool = file
+	return withCode(exitTransport, "message undelivered, spooled to "+file, err)
 }
 
-func sendMessage(c config) error {
-	resty.SetDebug(false)
-	message, err := messageRender(c.message)
+func sendMessage(ctx context.Context, c config, res *result) error {
+	client, err := newClient(c)
+	if err != nil {
+		return withCode(exitConfig, "", err)
+	}
+	if c.dm != "" {
+		c.channel, err = dmChannel(ctx, client, c)
+		if err != nil {
+			return err
+		}
+		res.Target = c.dm
+	}
+	message, err := renderMessage(ctx, c, client)
 	if err != nil {
 		return err
 	}
+	if c.input == "diff" {
+		if summary, upload := uploadedDiff(c); upload {
+			logDebug.Printf("diff longer than %d, uploading as a file", maxTextLength)
+			return uploadFile(ctx, client, c, diffFileUpload(c, summary), res)
+		}
+	}
+	if c.upload != "" {
+		if c.markdown != "" {
+			message = markdownToMrkdwn(message)
+		}
+		up, err := uploadFromFile(c.upload, c, slatemess.NewMessage(message).RawMrkdwn(c.rawMrkdwn).Text())
+		if err != nil {
+			return withCode(exitInput, "", err)
+		}
+		return uploadFile(ctx, client, c, up, res)
+	}
+	message, err = convertMarkdown(c.markdown, message)
+	if err != nil {
+		return withCode(exitValidation, "error converting markdown", err)
+	}
+	if !slatemess.IsJSON(message) && len([]rune(message)) > maxTextLength {
+		switch c.overflow {
+		case "truncate":
+			logDebug.Printf("message longer than %d, truncating", maxTextLength)
+			message = truncateText(message)
+		case "upload":
+			logDebug.Printf("message longer than %d, uploading as a file", maxTextLength)
+			return uploadFile(ctx, client, c, overflowFile(c, message), res)
+		}
+	}
 	payload, err := messageComplete(message, c)
 	if err != nil {
 		return err
 	}
 	logDebug.Printf("payload: %v", payload)
+	if !c.postAt.IsZero() {
+		return scheduleMessage(ctx, client, c, payload, res)
+	}
+	if c.viaAPI() {
+		return postMessage(ctx, client, c, payload, res)
+	}
 	if c.dry {
-		toCurl(c.hook, payload)
+		err := dryRun(c.dryFormat, c.hook, payload)
+		if err != nil {
+			return withCode(exitValidation, "", err)
+		}
 	} else {
-		err := toSlack(c.hook, payload)
+		res.Attempts++
+		err := client.Send(ctx, payload)
 		if err != nil {
-			return err
+			return undelivered(c, payload, err, res)
 		}
 	}
 
@@ -201,100 +411,65 @@ func sendMessage(c config) error {
 func readFileNameAsStr(filename string) (string, error) {
 	messageFile, err := os.Open(filename)
 	if err != nil {
-		return "", fmt.Errorf("ERROR opening file %v: %v", filename, err)
+		return "", fmt.Errorf("error opening file %v: %v", filename, err)
 	}
 	messageBytes, err := ioutil.ReadAll(messageFile)
 	if err != nil {
-		return "", fmt.Errorf("ERROR reading file %v: %v", filename, err)
+		return "", fmt.Errorf("error reading file %v: %v", filename, err)
 	}
 	return string(messageBytes), nil
 }
 
-func main() {
-	var cfg config
-	logDebug = log.New(os.Stderr, "[debug] ", log.LstdFlags)
-	godotenv.Load()
-	homeConfigPath, err := homedir.Expand("~/.slatemess")
-	if err == nil {
-		godotenv.Load(homeConfigPath)
-	}
-	godotenv.Load("/etc/slatemess.cfg")
-	godotenv.Load("/etc/slack.cfg")
-
-	fi, err := os.Stdin.Stat()
+// Verifies the config and sends the message, within -timeout
+func send(ctx context.Context, c config) (result, error) {
+	res := result{Target: target(c)}
+	err := c.verifyConfig()
 	if err != nil {
-		fmt.Printf("ERROR reading stdin > %v\n", err)
-		os.Exit(1)
+		return res, withCode(exitConfig, "error validating parameters", err)
 	}
-	piped := (fi.Mode() & os.ModeCharDevice) == 0
-
-	iconArg := flag.String("icon", "", "Override default icon from hook")
-	userArg := flag.String("user", "", "Override default user from hook")
-	channelArg := flag.String("channel", "", "Override default user from hook")
-	hookArg := flag.String("hook", "", "Override Hook provided by ENV, if any")
-	messageArg := flag.String("message", "", "Provide a message by parameter")
-	fileArg := flag.String("file", "", "Provide a message by file")
-	debugArg := flag.Bool("debug", false, "Print debug info")
-	fenceArg := flag.Bool("fence", false, "embed the text in a code fence, so it will be displayed as a code block")
-	dryArg := flag.Bool("dry", false, "Will not send the payload to slack but print a curl command equivalent, with the computed payload")
-	flag.Parse()
-
-	if *iconArg != "" {
-		os.Setenv("SLACK_ICON", *iconArg)
-	}
-	if *userArg != "" {
-		os.Setenv("SLACK_USER", *userArg)
+	ctx, cancel := withTimeout(ctx, c)
+	defer cancel()
+	err = sendMessage(ctx, c, &res)
+	if err != nil {
+		return res, err
 	}
-	if *hookArg != "" {
-		os.Setenv("SLACK_HOOK", *hookArg)
+	logDebug.Printf("Message Sent")
+	res.Status = "sent"
+	if !c.postAt.IsZero() {
+		res.Status = "scheduled"
 	}
-	if *channelArg != "" {
-		os.Setenv("SLACK_CHANNEL", *channelArg)
+	if c.dry {
+		res.Status = "dry"
 	}
-	if *fileArg != "" && *messageArg != "" {
-		fmt.Printf("ERROR: -file and -message mode are mutually exclusive\n")
-		os.Exit(1)
+	return res, nil
+}
+
+// Limits a context to -timeout, if any
+func withTimeout(ctx context.Context, c config) (context.Context, context.CancelFunc) {
+	if c.timeout <= 0 {
+		return ctx, func() {}
 	}
-	if !*debugArg {
-		logDebug.SetOutput(ioutil.Discard)
+	return context.WithTimeout(ctx, c.timeout)
+}
+
+// A context for commands calling slack on their own, cancelled by SIGINT,
+// SIGTERM and -timeout
+func commandContext(c config) (context.Context, context.CancelFunc) {
+	ctx, stop := signalContext()
+	ctx, cancel := withTimeout(ctx, c)
+	return ctx, func() {
+		cancel()
+		stop()
 	}
+}
 
-	// once here only work with env or "message"
-	cfg.hook = os.Getenv("SLACK_HOOK")
-	cfg.icon = os.Getenv("SLACK_ICON")
-	cfg.channel = os.Getenv("SLACK_CHANNEL")
-	cfg.userName = os.Getenv("SLACK_USER")
-	cfg.dry = *dryArg
-	if *messageArg != "" {
-		piped = false
-		cfg.message = *messageArg
-	}
-	if *fileArg != "" {
-		piped = false
-		msg, err := readFileNameAsStr(*fileArg)
-		if err != nil {
-			fmt.Println(err)
-			os.Exit(1)
-		}
-		cfg.message = msg
+// A context cancelled by SIGINT and SIGTERM
+func signalContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+}
 
-	}
-	if piped {
-		cfg.message = readStdin()
-	}
-	if *fenceArg {
-		cfg.message = fenceIt(cfg.message)
-	}
-	logDebug.Printf("Message: %#v", cfg)
-	err = cfg.verifyConfig()
-	if err != nil {
-		fmt.Printf("ERROR validating parameters: %v\n", err)
-		os.Exit(1)
-	}
-	err = sendMessage(cfg)
-	if err != nil {
-		fmt.Printf("ERROR Generating payload %v\n", err)
-		os.Exit(1)
-	}
-	logDebug.Printf("Message Sent")
+func main() {
+	logDebug = log.New(os.Stderr, "[debug] ", log.LstdFlags)
+	slatemess.Debug = logDebug
+	runCommand(os.Args[1:])
 }
@@ -0,0 +1,197 @@
+// Package slatemess renders and sends messages to Slack, using incoming
+// webhooks or the Web API. It's the library behind the slatemess command.
+package slatemess
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Jeffail/gabs/v2"
+	"gopkg.in/resty.v1"
+)
+
+// DefaultAPIURL is the base url for the slack Web API methods
+const DefaultAPIURL = "https://slack.com/api/"
+
+// Debug is where the package logs what it's doing, discarded by default
+var Debug = log.New(ioutil.Discard, "", 0)
+
+// Client sends payloads to an incoming webhook and calls Web API methods.
+// The hook is used by Send, and the token by Call and the API helpers like
+// Upload or Post, so a Client can have either or both.
+type Client struct {
+	Hook   string
+	Token  string
+	APIURL string
+	// HTTP is the client used for every request, to set timeouts, proxies
+	// and such
+	HTTP *resty.Client
+
+	transport *http.Transport
+	dialer    *net.Dialer
+}
+
+// File is a file to be uploaded and shared in a channel
+type File struct {
+	Name     string
+	Title    string
+	Type     string
+	Comment  string
+	Content  []byte
+	Channel  string
+	ThreadTS string
+}
+
+// NewClient returns a client for the hook and token, either can be empty
+func NewClient(hook, token string) *Client {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   10 * time.Second,
+		IdleConnTimeout:       90 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+	return &Client{
+		Hook:      hook,
+		Token:     token,
+		APIURL:    DefaultAPIURL,
+		HTTP:      resty.New().SetTransport(transport),
+		transport: transport,
+		dialer:    dialer,
+	}
+}
+
+// SetConnectTimeout limits the time to establish connections, including
+// the TLS handshake
+func (c *Client) SetConnectTimeout(timeout time.Duration) *Client {
+	c.dialer.Timeout = timeout
+	c.transport.TLSHandshakeTimeout = timeout
+	return c
+}
+
+// Send posts a payload to the webhook
+func (c *Client) Send(ctx context.Context, payload string) error {
+	res, err := c.HTTP.R().
+		SetContext(ctx).
+		SetBody(payload).
+		Post(c.Hook)
+	if err != nil {
+		return &TransportError{"sending message", err}
+	}
+	if res.IsError() {
+		return &SlackError{Op: "webhook", Status: res.Status(), Body: string(res.Body())}
+	}
+	return nil
+}
+
+// Call calls a Web API method with form params, returns the response once
+// checked for "ok"
+func (c *Client) Call(ctx context.Context, method string, params map[string]string) (*gabs.Container, error) {
+	res, err := c.HTTP.R().
+		SetContext(ctx).
+		SetAuthToken(c.Token).
+		SetFormData(params).
+		Post(c.APIURL + method)
+	if err != nil {
+		return nil, &TransportError{"calling " + method, err}
+	}
+	if res.IsError() {
+		return nil, &SlackError{Op: method, Status: res.Status(), Body: string(res.Body())}
+	}
+	js, err := gabs.ParseJSON(res.Body())
+	if err != nil {
+		return nil, &TransportError{"parsing " + method + " response", err}
+	}
+	if ok, _ := js.Path("ok").Data().(bool); !ok {
+		code, _ := js.Path("error").Data().(string)
+		return nil, &SlackError{Op: method, Status: res.Status(), Code: code, Body: string(res.Body())}
+	}
+	Debug.Printf("%v response: %v", method, js)
+	return js, nil
+}
+
+// PostPayload calls a Web API method taking a message, like
+// chat.postMessage, with the fields of a json payload as params. Extra
+// params are added to them.
+func (c *Client) PostPayload(ctx context.Context, method, payload string, extra map[string]string) (*gabs.Container, error) {
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(payload), &fields); err != nil {
+		return nil, &PayloadError{err}
+	}
+	params := make(map[string]string)
+	for key, value := range fields {
+		if s, ok := value.(string); ok {
+			params[key] = s
+			continue
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return nil, &PayloadError{err}
+		}
+		params[key] = string(encoded)
+	}
+	for key, value := range extra {
+		params[key] = value
+	}
+	return c.Call(ctx, method, params)
+}
+
+// Upload uploads a file using the external upload flow: asks for an upload
+// url, sends the content there and completes the upload sharing it in the
+// channel
+func (c *Client) Upload(ctx context.Context, f File) error {
+	if f.Title == "" {
+		f.Title = f.Name
+	}
+	params := map[string]string{
+		"filename": f.Name,
+		"length":   strconv.Itoa(len(f.Content)),
+	}
+	if f.Type != "" {
+		params["snippet_type"] = f.Type
+	}
+	js, err := c.Call(ctx, "files.getUploadURLExternal", params)
+	if err != nil {
+		return err
+	}
+	uploadURL, _ := js.Path("upload_url").Data().(string)
+	fileID, _ := js.Path("file_id").Data().(string)
+	if uploadURL == "" || fileID == "" {
+		return &SlackError{Op: "files.getUploadURLExternal", Status: "without upload_url or file_id", Body: js.String()}
+	}
+
+	res, err := c.HTTP.R().
+		SetContext(ctx).
+		SetBody(f.Content).
+		Post(uploadURL)
+	if err != nil {
+		return &TransportError{"uploading file " + f.Name, err}
+	}
+	if res.IsError() {
+		return &SlackError{Op: "upload", Status: res.Status(), Body: string(res.Body())}
+	}
+
+	files, err := json.Marshal([]map[string]string{{"id": fileID, "title": f.Title}})
+	if err != nil {
+		return err
+	}
+	params = map[string]string{
+		"files":      string(files),
+		"channel_id": f.Channel,
+	}
+	if f.Comment != "" {
+		params["initial_comment"] = Unmarkup(f.Comment)
+	}
+	if f.ThreadTS != "" {
+		params["thread_ts"] = f.ThreadTS
+	}
+	_, err = c.Call(ctx, "files.completeUploadExternal", params)
+	return err
+}
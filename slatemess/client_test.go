@@ -0,0 +1,45 @@
+package slatemess
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestUploadURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		wantErr  string
+	}{
+		{"no upload url", `{"ok":true,"file_id":"F1"}`, "without upload_url or file_id"},
+		{"empty upload url", `{"ok":true,"upload_url":"","file_id":"F1"}`, "without upload_url or file_id"},
+		{"no file id", `{"ok":true,"upload_url":"https://files.example.com/up"}`, "without upload_url or file_id"},
+		{"slack error", `{"ok":false,"error":"invalid_auth"}`, `"invalid_auth"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uploads := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if !strings.HasSuffix(r.URL.Path, "files.getUploadURLExternal") {
+					uploads++
+				}
+				w.Write([]byte(tt.response))
+			}))
+			defer server.Close()
+			client := NewClient("", "xoxb-1")
+			client.APIURL = server.URL + "/api/"
+			err := client.Upload(context.Background(), File{Name: "a.txt", Content: []byte("a"), Channel: "C1"})
+			var slackErr *SlackError
+			if !errors.As(err, &slackErr) || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Upload() error = %v, want a slack error with %q", err, tt.wantErr)
+			}
+			if uploads != 0 {
+				t.Errorf("Upload() made %d more requests, want none", uploads)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package slatemess
+
+import "fmt"
+
+// TemplateError is returned when a message template can't be parsed or
+// executed
+type TemplateError struct {
+	Err error
+}
+
+func (e *TemplateError) Error() string {
+	return fmt.Sprintf("error rendering slack template: %v", e.Err)
+}
+
+func (e *TemplateError) Unwrap() error {
+	return e.Err
+}
+
+// PayloadError is returned when a message can't be turned into a valid
+// payload
+type PayloadError struct {
+	Err error
+}
+
+func (e *PayloadError) Error() string {
+	return fmt.Sprintf("error generating slack payload: %v", e.Err)
+}
+
+func (e *PayloadError) Unwrap() error {
+	return e.Err
+}
+
+// TransportError is returned when a request can't reach slack, or its
+// response can't be read
+type TransportError struct {
+	Op  string
+	Err error
+}
+
+func (e *TransportError) Error() string {
+	return fmt.Sprintf("error %v: %v", e.Op, e.Err)
+}
+
+func (e *TransportError) Unwrap() error {
+	return e.Err
+}
+
+// SlackError is returned when slack rejects a request, either with an HTTP
+// error status or with an api error code
+type SlackError struct {
+	Op     string
+	Status string
+	Code   string
+	Body   string
+}
+
+func (e *SlackError) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("slack api %v returned an error \"%v\"", e.Op, e.Code)
+	}
+	return fmt.Sprintf("slack api %v returned an error %v \"%v\"", e.Op, e.Status, e.Body)
+}
@@ -0,0 +1,211 @@
+package slatemess
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	"github.com/Jeffail/gabs/v2"
+	"github.com/tidwall/pretty"
+)
+
+// markup is wrapped in these so it survives escaping, they are removed once
+// the payload is complete
+const (
+	markupStart = "\uE000"
+	markupEnd   = "\uE001"
+)
+
+var markupRe = regexp.MustCompile(markupStart + "[^" + markupEnd + "]*" + markupEnd)
+
+// Message builds a slack payload from a rendered message. The body can be
+// a json payload or plain text, that will be sent as the payload text.
+type Message struct {
+	body      string
+	channel   string
+	username  string
+	icon      string
+	iconURL   string
+	rawMrkdwn bool
+	override  bool
+	// the channel replaces the payload one even without override
+	forceChannel bool
+}
+
+// NewMessage returns a message with the given body
+func NewMessage(body string) *Message {
+	return &Message{body: body}
+}
+
+// Channel sets the channel, unless the payload already has one
+func (m *Message) Channel(channel string) *Message {
+	m.channel = channel
+	return m
+}
+
+// Username sets the username, unless the payload already has one
+func (m *Message) Username(username string) *Message {
+	m.username = username
+	return m
+}
+
+// Icon sets the icon emoji, unless the payload already has one
+func (m *Message) Icon(emoji string) *Message {
+	m.icon = emoji
+	return m
+}
+
+// IconURL sets the url of an image for the icon, unless the payload
+// already has one
+func (m *Message) IconURL(url string) *Message {
+	m.iconURL = url
+	return m
+}
+
+// Override makes the channel, username and icons replace the ones a json
+// payload already has, instead of being used only when it hasn't them
+func (m *Message) Override(override bool) *Message {
+	m.override = override
+	return m
+}
+
+// ForceChannel sets the channel replacing the one the payload has, for
+// messages that can't go anywhere else, like direct messages
+func (m *Message) ForceChannel(channel string) *Message {
+	m.channel = channel
+	m.forceChannel = true
+	return m
+}
+
+// Ignored returns the channel, username and icon that won't be used because
+// the json payload already has others, by payload field
+func (m *Message) Ignored() map[string]string {
+	ignored := make(map[string]string)
+	if m.override || !IsJSON(m.body) {
+		return ignored
+	}
+	js, err := gabs.ParseJSON([]byte(m.body))
+	if err != nil {
+		return ignored
+	}
+	fields := map[string]string{"channel": m.channel, "username": m.username, "icon_emoji": m.icon, "icon_url": m.iconURL}
+	for key, value := range fields {
+		if key == "channel" && m.forceChannel {
+			continue
+		}
+		if value != "" && js.Exists(key) && js.Path(key).Data() != value {
+			ignored[key] = value
+		}
+	}
+	return ignored
+}
+
+// RawMrkdwn disables the escaping of plain text bodies, to send markup
+// like <@U123> or <url|text>
+func (m *Message) RawMrkdwn(raw bool) *Message {
+	m.rawMrkdwn = raw
+	return m
+}
+
+// IsJSON tells if the message is a json object, so it's sent as a payload
+func IsJSON(s string) bool {
+	var js map[string]interface{}
+
+	ugly := pretty.Ugly([]byte(s))
+	Debug.Printf("checking json for: %v", string(ugly))
+	err := json.Unmarshal(ugly, &js)
+	if err != nil {
+		Debug.Printf("Isn't json because: %v", err)
+		return false
+	}
+	return true
+}
+
+// Markup marks text as slack markup, so it's not escaped in plain text
+// messages
+func Markup(markup string) string {
+	return markupStart + markup + markupEnd
+}
+
+// Unmarkup removes the marks added by Markup
+func Unmarkup(text string) string {
+	return strings.NewReplacer(markupStart, "", markupEnd, "").Replace(text)
+}
+
+// EscapeMrkdwn escapes the characters slack uses for its markup, leaving
+// alone the text marked with Markup
+func EscapeMrkdwn(text string) string {
+	escaper := strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+	escaped := ""
+	last := 0
+	for _, span := range markupRe.FindAllStringIndex(text, -1) {
+		escaped += escaper.Replace(text[last:span[0]]) + text[span[0]:span[1]]
+		last = span[1]
+	}
+	return escaped + escaper.Replace(text[last:])
+}
+
+// Trims the message and escapes it unless it's meant to carry raw mrkdwn
+func messageSafe(message string, raw bool) string {
+	safe := strings.TrimSpace(message)
+	if !raw {
+		safe = EscapeMrkdwn(safe)
+	}
+	return safe
+}
+
+// Text returns the body as it would be sent as plain text
+func (m *Message) Text() string {
+	return Unmarkup(messageSafe(m.body, m.rawMrkdwn))
+}
+
+func setDefault(js *gabs.Container, key, value string, override bool) {
+	if value == "" {
+		return
+	}
+	if js.Exists(key) && !override {
+		Debug.Printf("WARN: %v in the payload, your specified %v %v won't be used", key, key, value)
+		return
+	}
+	js.Set(value, key)
+}
+
+// Payload returns the json payload for the message
+func (m *Message) Payload() (string, error) {
+	msg := m.body
+	if !IsJSON(m.body) {
+		var err error
+		msg, err = MarshalPayload(map[string]string{"text": messageSafe(m.body, m.rawMrkdwn)})
+		if err != nil {
+			return "", &PayloadError{err}
+		}
+	}
+	js, err := gabs.ParseJSON([]byte(msg))
+	if err != nil {
+		return "", &PayloadError{err}
+	}
+	setDefault(js, "channel", m.channel, m.override || m.forceChannel)
+	setDefault(js, "username", m.username, m.override)
+	setDefault(js, "icon_emoji", m.icon, m.override)
+	setDefault(js, "icon_url", m.iconURL, m.override)
+
+	Debug.Printf("gabs object +%v", js)
+	payload, err := MarshalPayload(js.Data())
+	if err != nil {
+		return "", &PayloadError{err}
+	}
+	return Unmarkup(payload), nil
+}
+
+// MarshalPayload encodes a payload as json without escaping &, < and >,
+// that slack doesn't need and would make the escaped text unreadable
+func MarshalPayload(v interface{}) (string, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
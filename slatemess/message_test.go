@@ -0,0 +1,73 @@
+package slatemess
+
+import "testing"
+
+func TestEscapeMrkdwn(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"plain", "hello", "hello"},
+		{"special chars", "a & b < c > d", "a &amp; b &lt; c &gt; d"},
+		{"already escaped", "&amp;", "&amp;amp;"},
+		{"markup kept", "hi " + Markup("<@U123>") + " & bye", "hi " + Markup("<@U123>") + " &amp; bye"},
+		{"markup only", Markup("<!here>"), Markup("<!here>")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EscapeMrkdwn(tt.text); got != tt.want {
+				t.Errorf("EscapeMrkdwn() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarshalPayload(t *testing.T) {
+	tests := []struct {
+		name string
+		v    interface{}
+		want string
+	}{
+		{"text", map[string]string{"text": "hi"}, `{"text":"hi"}`},
+		{"html chars not escaped", map[string]string{"text": "&amp; <b> & >"}, `{"text":"&amp; <b> & >"}`},
+		{"quotes and newlines", map[string]string{"text": "a \"b\"\nc"}, `{"text":"a \"b\"\nc"}`},
+		{"nested", map[string]interface{}{"blocks": []interface{}{map[string]string{"type": "divider"}}}, `{"blocks":[{"type":"divider"}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MarshalPayload(tt.v)
+			if err != nil {
+				t.Fatalf("MarshalPayload() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("MarshalPayload() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPayloadText(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		raw  bool
+		want string
+	}{
+		{"escaped", "deploy <main> & done", false, `{"text":"deploy &lt;main&gt; &amp; done"}`},
+		{"raw mrkdwn", "ping <@U123> & done", true, `{"text":"ping <@U123> & done"}`},
+		{"markup unmarked", "ping " + Markup("<@U123>") + " <x>", false, `{"text":"ping <@U123> &lt;x&gt;"}`},
+		{"json body kept", `{"text":"<b>"}`, false, `{"text":"<b>"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewMessage(tt.body).RawMrkdwn(tt.raw).Payload()
+			if err != nil {
+				t.Fatalf("Payload() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Payload() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
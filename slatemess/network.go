@@ -0,0 +1,121 @@
+package slatemess
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"gopkg.in/resty.v1"
+)
+
+// Network holds the connection settings for networks that can't reach
+// slack directly. Empty fields keep the defaults.
+type Network struct {
+	// Proxy overrides the HTTPS_PROXY env variable
+	Proxy         string
+	ProxyUser     string
+	ProxyPassword string
+	// CABundle is a PEM file with certificates trusted besides the system
+	// ones, for proxies intercepting TLS
+	CABundle string
+	// ClientCert and ClientKey are PEM files for TLS client authentication
+	ClientCert string
+	ClientKey  string
+	// AllowedHosts limits the hosts requests and redirects can go to
+	AllowedHosts []string
+}
+
+// Returns the proxy to use, nil keeps the one from the environment
+func proxyURL(n Network) (*url.URL, error) {
+	if n.Proxy == "" && n.ProxyUser == "" {
+		return nil, nil
+	}
+	proxy := n.Proxy
+	if proxy == "" {
+		proxy = os.Getenv("HTTPS_PROXY")
+	}
+	if proxy == "" {
+		proxy = os.Getenv("https_proxy")
+	}
+	if proxy == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy %v: %v", proxy, err)
+	}
+	if n.ProxyUser != "" {
+		u.User = url.UserPassword(n.ProxyUser, n.ProxyPassword)
+	}
+	return u, nil
+}
+
+func tlsConfig(n Network) (*tls.Config, error) {
+	config := &tls.Config{}
+	if n.CABundle != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		pem, err := ioutil.ReadFile(n.CABundle)
+		if err != nil {
+			return nil, fmt.Errorf("error reading CA bundle: %v", err)
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %v", n.CABundle)
+		}
+		config.RootCAs = pool
+	}
+	if n.ClientCert != "" || n.ClientKey != "" {
+		cert, err := tls.LoadX509KeyPair(n.ClientCert, n.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("error loading client certificate: %v", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// Checks the host of every request against the allowed ones
+func allowHosts(hosts []string) func(*resty.Client, *resty.Request) error {
+	allowed := make(map[string]bool)
+	for _, host := range hosts {
+		allowed[strings.ToLower(host)] = true
+	}
+	return func(_ *resty.Client, r *resty.Request) error {
+		u, err := url.Parse(r.URL)
+		if err != nil {
+			return err
+		}
+		if !allowed[strings.ToLower(u.Hostname())] {
+			return fmt.Errorf("host %v is not in the allowed hosts", u.Hostname())
+		}
+		return nil
+	}
+}
+
+// SetNetwork applies the network settings to the client
+func (c *Client) SetNetwork(n Network) error {
+	proxy, err := proxyURL(n)
+	if err != nil {
+		return err
+	}
+	if proxy != nil {
+		c.transport.Proxy = http.ProxyURL(proxy)
+	}
+	config, err := tlsConfig(n)
+	if err != nil {
+		return err
+	}
+	c.transport.TLSClientConfig = config
+	if len(n.AllowedHosts) > 0 {
+		c.HTTP.OnBeforeRequest(allowHosts(n.AllowedHosts))
+		c.HTTP.SetRedirectPolicy(resty.DomainCheckRedirectPolicy(n.AllowedHosts...))
+	}
+	return nil
+}
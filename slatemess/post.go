@@ -0,0 +1,58 @@
+package slatemess
+
+import "context"
+
+// Post posts a payload with chat.postMessage, in the channel of the
+// payload, returns the channel id and ts of the message
+func (c *Client) Post(ctx context.Context, payload string) (string, string, error) {
+	js, err := c.PostPayload(ctx, "chat.postMessage", payload, nil)
+	if err != nil {
+		return "", "", err
+	}
+	channel, _ := js.Path("channel").Data().(string)
+	ts, _ := js.Path("ts").Data().(string)
+	return channel, ts, nil
+}
+
+// PostEphemeral posts a payload in its channel visible only to the user,
+// who must be in the channel, returns the ts of the message
+func (c *Client) PostEphemeral(ctx context.Context, payload, user string) (string, error) {
+	js, err := c.PostPayload(ctx, "chat.postEphemeral", payload, map[string]string{"user": user})
+	if err != nil {
+		return "", err
+	}
+	ts, _ := js.Path("message_ts").Data().(string)
+	return ts, nil
+}
+
+// OpenDM opens, or finds, the direct message channel with a user, returns
+// its id
+func (c *Client) OpenDM(ctx context.Context, user string) (string, error) {
+	js, err := c.Call(ctx, "conversations.open", map[string]string{"users": user})
+	if err != nil {
+		return "", err
+	}
+	id, _ := js.Path("channel.id").Data().(string)
+	return id, nil
+}
+
+// AddReaction reacts to a message with an emoji, given by name without
+// colons
+func (c *Client) AddReaction(ctx context.Context, channel, ts, emoji string) error {
+	_, err := c.Call(ctx, "reactions.add", map[string]string{
+		"channel":   channel,
+		"timestamp": ts,
+		"name":      emoji,
+	})
+	return err
+}
+
+// RemoveReaction removes a reaction added by the token to a message
+func (c *Client) RemoveReaction(ctx context.Context, channel, ts, emoji string) error {
+	_, err := c.Call(ctx, "reactions.remove", map[string]string{
+		"channel":   channel,
+		"timestamp": ts,
+		"name":      emoji,
+	})
+	return err
+}
@@ -0,0 +1,25 @@
+package slatemess
+
+import (
+	"bytes"
+	"text/template"
+)
+
+// Render executes a message template with data. Optional funcs are added
+// to the template functions, like the mention helpers of the command line.
+func Render(tmpl string, data interface{}, funcs ...template.FuncMap) (string, error) {
+	var render bytes.Buffer
+	t := template.New("message")
+	for _, f := range funcs {
+		t = t.Funcs(f)
+	}
+	t, err := t.Parse(tmpl)
+	if err != nil {
+		return "", &TemplateError{err}
+	}
+	err = t.Execute(&render, data)
+	if err != nil {
+		return "", &TemplateError{err}
+	}
+	return render.String(), nil
+}
@@ -0,0 +1,75 @@
+package slatemess
+
+import (
+	"context"
+	"strconv"
+	"time"
+)
+
+// MaxScheduleAhead is how far in the future slack accepts scheduled
+// messages
+const MaxScheduleAhead = 120 * 24 * time.Hour
+
+// Scheduled is a message scheduled to be posted
+type Scheduled struct {
+	ID      string
+	Channel string
+	PostAt  time.Time
+	Text    string
+}
+
+// Schedule schedules a payload to be posted in its channel at the given
+// time, returns the id of the scheduled message
+func (c *Client) Schedule(ctx context.Context, payload string, at time.Time) (string, error) {
+	js, err := c.PostPayload(ctx, "chat.scheduleMessage", payload, map[string]string{
+		"post_at": strconv.FormatInt(at.Unix(), 10),
+	})
+	if err != nil {
+		return "", err
+	}
+	id, _ := js.Path("scheduled_message_id").Data().(string)
+	return id, nil
+}
+
+// ScheduledMessages lists the messages scheduled by the token, in a
+// channel or, if it's empty, in every channel
+func (c *Client) ScheduledMessages(ctx context.Context, channel string) ([]Scheduled, error) {
+	var scheduled []Scheduled
+	cursor := ""
+	for {
+		params := map[string]string{"limit": "100"}
+		if channel != "" {
+			params["channel"] = channel
+		}
+		if cursor != "" {
+			params["cursor"] = cursor
+		}
+		js, err := c.Call(ctx, "chat.scheduledMessages.list", params)
+		if err != nil {
+			return nil, err
+		}
+		for _, message := range js.Path("scheduled_messages").Children() {
+			s := Scheduled{}
+			s.ID, _ = message.Path("id").Data().(string)
+			s.Channel, _ = message.Path("channel_id").Data().(string)
+			s.Text, _ = message.Path("text").Data().(string)
+			if postAt, ok := message.Path("post_at").Data().(float64); ok {
+				s.PostAt = time.Unix(int64(postAt), 0)
+			}
+			scheduled = append(scheduled, s)
+		}
+		cursor, _ = js.Path("response_metadata.next_cursor").Data().(string)
+		if cursor == "" {
+			return scheduled, nil
+		}
+	}
+}
+
+// DeleteScheduled deletes a scheduled message before it's posted
+func (c *Client) DeleteScheduled(ctx context.Context, channel, id string) error {
+	_, err := c.Call(ctx, "chat.deleteScheduledMessage", map[string]string{
+		"channel":              channel,
+		"scheduled_message_id": id,
+	})
+	return err
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// a message that couldn't be delivered, kept to be sent later. The hook is
+// kept as a hash, so the message is only sent to the hook it was for.
+type spooled struct {
+	Created time.Time `json:"created"`
+	Payload string    `json:"payload"`
+	Error   string    `json:"error"`
+	Profile string    `json:"profile,omitempty"`
+	HookID  string    `json:"hook_id,omitempty"`
+}
+
+func spoolDir() string {
+	return filepath.Join(slatemessDir(), "spool")
+}
+
+// Identifies a hook without keeping it
+func hookID(hook string) string {
+	sum := sha256.Sum256([]byte(hook))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Saves an undelivered payload in the spool, returns the file used
+func spoolPayload(c config, payload string, reason error) (string, error) {
+	msg := spooled{Created: time.Now(), Payload: payload, Error: reason.Error(), Profile: c.profile, HookID: hookID(c.hook)}
+	content, err := json.MarshalIndent(msg, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	err = os.MkdirAll(spoolDir(), 0700)
+	if err != nil {
+		return "", fmt.Errorf("error creating spool: %v", err)
+	}
+	file := filepath.Join(spoolDir(), fmt.Sprintf("%d.json", msg.Created.UnixNano()))
+	err = ioutil.WriteFile(file, content, 0600)
+	if err != nil {
+		return "", fmt.Errorf("error writing spool: %v", err)
+	}
+	return file, nil
+}
+
+// Spooled files, oldest first
+func spoolFiles() ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(spoolDir(), "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// Sends the spooled messages for the current hook, removing the ones
+// delivered. The ones spooled for other hooks are skipped, and it stops at
+// the first failure so the order is kept.
+func flushSpool(ctx context.Context, c config, res *result) error {
+	client, err := newClient(c)
+	if err != nil {
+		return withCode(exitConfig, "", err)
+	}
+	files, err := spoolFiles()
+	if err != nil {
+		return withCode(exitInput, "error reading spool", err)
+	}
+	for _, file := range files {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return withCode(exitInput, "error reading spool", err)
+		}
+		var msg spooled
+		err = json.Unmarshal(content, &msg)
+		if err != nil {
+			return withCode(exitInput, "error reading spool file "+file, err)
+		}
+		if msg.HookID != "" && msg.HookID != hookID(c.hook) {
+			fmt.Fprintf(os.Stderr, "WARN skipping %v, it was spooled for another hook%v\n", file, spooledProfile(msg))
+			continue
+		}
+		if c.dry {
+			err = dryRun(c.dryFormat, c.hook, msg.Payload)
+			if err != nil {
+				return withCode(exitValidation, "", err)
+			}
+			continue
+		}
+		res.Attempts++
+		err = client.Send(ctx, msg.Payload)
+		if err != nil {
+			return withCode(exitCode(err), "error flushing "+file, err)
+		}
+		logDebug.Printf("flushed %v", file)
+		os.Remove(file)
+	}
+	return nil
+}
+
+func spooledProfile(msg spooled) string {
+	if msg.Profile == "" {
+		return ""
+	}
+	return ", flush it with -profile " + msg.Profile
+}
+
+func setupFlush(fs *flag.FlagSet) func([]string) {
+	f := addConfigFlags(fs)
+	dry := fs.Bool("dry", false, "Print the spooled payloads as curl commands instead of sending them")
+	return func(args []string) {
+		c, err := f.config()
+		if err != nil {
+			exit(f.outputMode(), result{}, err)
+		}
+		c.dry = *dry
+		c.dryFormat = "curl"
+		res := result{Target: target(c)}
+		u, err := url.Parse(c.hook)
+		if err != nil || u.Scheme != "https" {
+			exit(c.output, res, withCode(exitConfig, "", fmt.Errorf("invalid hook %v", c.hook)))
+		}
+		ctx, stop := commandContext(c)
+		defer stop()
+		err = flushSpool(ctx, c, &res)
+		if err == nil {
+			res.Status = "flushed"
+		}
+		if err == nil && c.dry {
+			res.Status = "dry"
+		}
+		stop()
+		exit(c.output, res, err)
+	}
+}
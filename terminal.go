@@ -0,0 +1,79 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+var (
+	// CSI sequences like colors and cursor moves, OSC ones like titles and
+	// links, and the two character ones
+	ansiRe = regexp.MustCompile(`\x1b\[[0-?]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(\x07|\x1b\\)|\x1b[@-Z\\-_]`)
+	// colors set on a line, by SGR sequences
+	sgrRe = regexp.MustCompile(`\x1b\[([0-9;]*)m`)
+)
+
+// emoji marking the lines printed in red or green with -ansi-markers
+var ansiMarkers = map[string]string{
+	"31": ":red_circle:",
+	"91": ":red_circle:",
+	"32": ":large_green_circle:",
+	"92": ":large_green_circle:",
+}
+
+func stripANSI(text string) string {
+	return ansiRe.ReplaceAllString(text, "")
+}
+
+// The marker for the first red or green color set in a line
+func lineMarker(line string) string {
+	for _, m := range sgrRe.FindAllStringSubmatch(line, -1) {
+		for _, param := range strings.Split(m[1], ";") {
+			if marker, ok := ansiMarkers[param]; ok {
+				return marker
+			}
+		}
+	}
+	return ""
+}
+
+// Applies the backspaces of a line and removes the control characters
+func cleanLine(line string) string {
+	var clean []rune
+	for _, r := range line {
+		switch {
+		case r == '\b':
+			if len(clean) > 0 {
+				clean = clean[:len(clean)-1]
+			}
+		case r == '\t' || !unicode.IsControl(r):
+			clean = append(clean, r)
+		}
+	}
+	return string(clean)
+}
+
+// Cleans the output of terminal programs for slack: removes ANSI escape
+// sequences, progress bar redraws and control characters. With markers,
+// lines printed in red or green start with an emoji.
+func cleanTerminal(text string, markers bool) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		// a terminal ends showing what's after the last carriage return,
+		// those redraws are dropped first so their colors are too
+		if j := strings.LastIndex(strings.TrimSuffix(line, "\r"), "\r"); j >= 0 {
+			line = line[j+1:]
+		}
+		marker := ""
+		if markers {
+			marker = lineMarker(line)
+		}
+		line = cleanLine(stripANSI(line))
+		if marker != "" && strings.TrimSpace(line) != "" {
+			line = marker + " " + line
+		}
+		lines[i] = line
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,32 @@
+package main
+
+import "testing"
+
+func TestCleanTerminal(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		markers bool
+		want    string
+	}{
+		{"plain", "hello\nworld", false, "hello\nworld"},
+		{"colors", "\x1b[1;31mFAIL\x1b[0m ok", false, "FAIL ok"},
+		{"cursor moves", "\x1b[2K\x1b[1Gdone", false, "done"},
+		{"osc title and link", "\x1b]0;title\x07\x1b]8;;https://x\x1b\\link\x1b]8;;\x1b\\", false, "link"},
+		{"progress redraws", "10%\r50%\r100%\nnext", false, "100%\nnext"},
+		{"crlf line ends", "one\r\ntwo\r\n", false, "one\ntwo\n"},
+		{"backspaces", "abc\b\bd", false, "ad"},
+		{"control chars dropped, tabs kept", "a\x07b\tc\x00", false, "ab\tc"},
+		{"markers", "\x1b[31mFAIL x\x1b[0m\n\x1b[32mok y\x1b[0m\nplain", true, ":red_circle: FAIL x\n:large_green_circle: ok y\nplain"},
+		{"bright and combined markers", "\x1b[1;91merr\x1b[0m\n\x1b[0;92mgood", true, ":red_circle: err\n:large_green_circle: good"},
+		{"no marker on empty lines", "\x1b[31m\x1b[0m", true, ""},
+		{"markers off", "\x1b[31mFAIL\x1b[0m", false, "FAIL"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cleanTerminal(tt.text, tt.markers); got != tt.want {
+				t.Errorf("cleanTerminal() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
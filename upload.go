@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/theist/slatemess/slatemess"
+)
+
+// longer plain text messages are handled by the -overflow mode
+const maxTextLength = 4000
+
+func overflowModes() []string {
+	return []string{"send", "truncate", "upload"}
+}
+
+func truncateText(text string) string {
+	suffix := "\n... (truncated)"
+	runes := []rune(text)
+	if len(runes) <= maxTextLength {
+		return text
+	}
+	return string(runes[:maxTextLength-len(suffix)]) + suffix
+}
+
+func uploadFromFile(path string, c config, comment string) (slatemess.File, error) {
+	content, err := readFileNameAsStr(path)
+	if err != nil {
+		return slatemess.File{}, err
+	}
+	up := slatemess.File{
+		Name:     filepath.Base(path),
+		Title:    c.uploadTitle,
+		Type:     c.uploadType,
+		Comment:  comment,
+		Content:  []byte(content),
+		Channel:  c.channel,
+		ThreadTS: c.threadTS,
+	}
+	return up, nil
+}
+
+// the text of a message too long to be sent, as a file
+func overflowFile(c config, message string) slatemess.File {
+	return slatemess.File{
+		Name:     "message.txt",
+		Title:    c.uploadTitle,
+		Type:     c.uploadType,
+		Content:  []byte(slatemess.Unmarkup(message)),
+		Channel:  c.channel,
+		ThreadTS: c.threadTS,
+	}
+}
+
+func uploadFile(ctx context.Context, client *slatemess.Client, c config, up slatemess.File, res *result) error {
+	if c.dry {
+		title := up.Title
+		if title == "" {
+			title = up.Name
+		}
+		fmt.Printf("upload %v (%d bytes) as %q to channel %v\n", up.Name, len(up.Content), title, up.Channel)
+		return nil
+	}
+	res.Attempts++
+	return client.Upload(ctx, up)
+}
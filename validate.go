@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/Jeffail/gabs/v2"
+)
+
+// slack limits for payloads
+const (
+	maxPayloadText = 40000
+	maxBlocks      = 50
+	maxSectionText = 3000
+	maxFieldText   = 2000
+)
+
+func textLength(js *gabs.Container, path string) int {
+	text, _ := js.Path(path).Data().(string)
+	return len([]rune(text))
+}
+
+// Checks a payload against the slack limits, returns the problems found
+func validatePayload(payload string) []string {
+	var problems []string
+	js, err := gabs.ParseJSON([]byte(payload))
+	if err != nil {
+		return []string{fmt.Sprintf("payload isn't valid json: %v", err)}
+	}
+	if !js.Exists("text") && !js.Exists("blocks") && !js.Exists("attachments") {
+		problems = append(problems, "payload has no text, blocks or attachments")
+	}
+	if l := textLength(js, "text"); l > maxPayloadText {
+		problems = append(problems, fmt.Sprintf("text is %d characters long, max is %d", l, maxPayloadText))
+	}
+	blocks := js.Path("blocks").Children()
+	if len(blocks) > maxBlocks {
+		problems = append(problems, fmt.Sprintf("payload has %d blocks, max is %d", len(blocks), maxBlocks))
+	}
+	for i, block := range blocks {
+		kind, _ := block.Path("type").Data().(string)
+		switch kind {
+		case "":
+			problems = append(problems, fmt.Sprintf("block %d has no type", i))
+		case "header":
+			if l := textLength(block, "text.text"); l > maxHeaderLength {
+				problems = append(problems, fmt.Sprintf("header block %d text is %d characters long, max is %d", i, l, maxHeaderLength))
+			}
+		case "section":
+			if !block.Exists("text") && !block.Exists("fields") {
+				problems = append(problems, fmt.Sprintf("section block %d has no text or fields", i))
+			}
+			if l := textLength(block, "text.text"); l > maxSectionText {
+				problems = append(problems, fmt.Sprintf("section block %d text is %d characters long, max is %d", i, l, maxSectionText))
+			}
+			fields := block.Path("fields").Children()
+			if len(fields) > maxFields {
+				problems = append(problems, fmt.Sprintf("section block %d has %d fields, max is %d", i, len(fields), maxFields))
+			}
+			for j, field := range fields {
+				if l := textLength(field, "text"); l > maxFieldText {
+					problems = append(problems, fmt.Sprintf("section block %d field %d is %d characters long, max is %d", i, j, l, maxFieldText))
+				}
+			}
+		}
+	}
+	return problems
+}
+
+func setupValidate(fs *flag.FlagSet) func([]string) {
+	f := addMessageFlags(fs)
+	return func(args []string) {
+		c, err := f.config(true)
+		if err == nil {
+			err = noArgs(args)
+		}
+		if err != nil {
+			exit(f.outputMode(), result{}, err)
+		}
+		res := result{Target: target(c), Status: "valid"}
+		if c.message == "" {
+			exit(c.output, res, withCode(exitInput, "", fmt.Errorf("missing message")))
+		}
+		ctx, stop := signalContext()
+		defer stop()
+		payload, err := buildPayload(ctx, c)
+		stop()
+		if err != nil {
+			exit(c.output, res, err)
+		}
+		problems := validatePayload(payload)
+		if len(problems) > 0 {
+			res.Status = "invalid"
+			exit(c.output, res, withCode(exitValidation, "invalid payload", errors.New(strings.Join(problems, "; "))))
+		}
+		if c.output != "json" {
+			fmt.Println("payload is valid")
+		}
+		exit(c.output, res, nil)
+	}
+}